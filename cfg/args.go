@@ -0,0 +1,326 @@
+package cfg
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	oferrors "github.com/hackborn/onefunc/errors"
+)
+
+// ArgsOpts configures WithArgs.
+type ArgsOpts struct {
+	// Optional schema, used to infer the type of each flag
+	// and to document the flags in the help. Unless the schema
+	// allows unknown keys, undeclared flags are errors.
+	Schema *Schema
+
+	// Optional default settings, used to infer the type of
+	// each flag and to list the flags in the help.
+	Defaults Settings
+
+	// Optional destination for arguments that aren't flags.
+	// If nil, they are errors.
+	Positional *[]string
+
+	// Optional destination for the help. Defaults to os.Stdout.
+	Output io.Writer
+}
+
+// ErrHelp is the error added by WithArgs when the help is requested.
+var ErrHelp = errors.New("cfg: help requested")
+
+// WithArgs adds settings from command line arguments, generally
+// os.Args[1:]. Each flag names a settings path, with "." or "/"
+// separators, and its value can follow an "=" or be the next
+// argument:
+//
+//	--net.port=8080 --net/host localhost --verbose --tags a --tags b
+//
+// Values are converted to the type of the path in the schema or
+// defaults. Flags with a bool type don't need a value. Repeating a
+// slice flag appends to the slice. Values of unknown type are decoded
+// as JSON when possible, otherwise they are strings, and repeated
+// unknown flags become a slice. A flag that would replace a value,
+// or the settings below a path, set by an earlier flag is an error.
+// "--" ends the flags.
+//
+// "--help" or "-h" writes a description of every known path and
+// adds ErrHelp.
+func WithArgs(args []string, opts ArgsOpts) Option {
+	return func(b Builder, eb oferrors.Block) {
+		a := &argsParser{opts: opts, s: b.NewSettings(), counts: make(map[string]int)}
+		for i := 0; i < len(args); i++ {
+			arg := args[i]
+			if arg == "--" {
+				a.positional(eb, args[i+1:]...)
+				break
+			} else if !strings.HasPrefix(arg, "-") || arg == "-" {
+				a.positional(eb, arg)
+				continue
+			}
+			name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+			if name == "help" || name == "h" {
+				a.help()
+				eb.AddError(ErrHelp)
+				return
+			} else if argPath(name) == "" {
+				eb.AddError(fmt.Errorf("flag %v: needs a setting path", arg))
+				continue
+			}
+			typ, elemType, known := a.typeOf(argPath(name))
+			if !known && opts.Schema != nil && !opts.Schema.AllowUnknown {
+				eb.AddError(fmt.Errorf("flag --%v: unknown setting", name))
+				continue
+			}
+			if !hasValue {
+				next := i+1 < len(args) && args[i+1] != "--"
+				if typ == TypeBool || (typ == "" && (!next || strings.HasPrefix(args[i+1], "-"))) {
+					value = "true"
+				} else if next {
+					i++
+					value = args[i]
+				} else {
+					eb.AddError(fmt.Errorf("flag --%v: needs a value", name))
+					continue
+				}
+			}
+			eb.AddError(a.set(name, value, typ, elemType, known))
+		}
+		setSource(b, func(keys []string) Source {
+			return Source{Kind: SourceArg, Name: strings.Join(keys, pathSeparator)}
+		})
+		b.AddSettings(a.s)
+	}
+}
+
+type argsParser struct {
+	opts ArgsOpts
+	s    tree
+	// counts is the number of times each path was set.
+	counts map[string]int
+}
+
+func (a *argsParser) positional(eb oferrors.Block, args ...string) {
+	if a.opts.Positional != nil {
+		*a.opts.Positional = append(*a.opts.Positional, args...)
+	} else if len(args) > 0 {
+		eb.AddError(fmt.Errorf("unexpected argument \"%v\"", args[0]))
+	}
+}
+
+// set assigns the flag value. Slices, and repeated
+// flags of unknown type, append.
+func (a *argsParser) set(name, value, typ, elemType string, known bool) error {
+	path := argPath(name)
+	parseType := typ
+	if typ == TypeSlice {
+		parseType = elemType
+	}
+	v, err := parseArg(value, parseType)
+	if err != nil {
+		return fmt.Errorf("flag --%v: %w", name, err)
+	}
+	if err := a.checkConflict(path); err != nil {
+		return fmt.Errorf("flag --%v: %w", name, err)
+	}
+	count := a.counts[path]
+	a.counts[path] = count + 1
+	current, _ := (Settings{t: a.s}).lookup(path)
+	switch {
+	case typ == TypeSlice && count > 0, !known && count > 1:
+		list, ok := current.([]any)
+		if !ok {
+			return fmt.Errorf("flag --%v: can't append to %T", name, current)
+		}
+		v = append(list, v)
+	case typ == TypeSlice:
+		v = []any{v}
+	case !known && count > 0:
+		v = []any{current, v}
+	}
+	setPath(a.s, path, v)
+	return nil
+}
+
+// checkConflict answers an error if setting the path would
+// replace an earlier flag: either a value above the path, or
+// settings below it.
+func (a *argsParser) checkConflict(path string) error {
+	var v any = a.s
+	keys := strings.Split(path, pathSeparator)
+	for i, k := range keys {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("conflicts with the value of --%v", strings.Join(keys[:i], "."))
+		}
+		if v, ok = m[k]; !ok {
+			return nil
+		}
+	}
+	if _, ok := v.(map[string]any); ok {
+		return fmt.Errorf("conflicts with the settings below it")
+	}
+	return nil
+}
+
+// typeOf answers the type of the path from the schema or
+// defaults, the type of slice elements if known, and whether
+// the path is known.
+func (a *argsParser) typeOf(path string) (string, string, bool) {
+	elemType, isSlice := a.sliceType(path)
+	if isSlice {
+		return TypeSlice, elemType, true
+	}
+	if a.opts.Schema != nil {
+		p := strings.Split(path, pathSeparator)
+		for _, k := range a.opts.Schema.Keys {
+			kp := strings.Split(strings.Trim(k.Path, pathSeparator), pathSeparator)
+			if matchPath(kp, p) {
+				if k.Type == TypeAny {
+					return "", "", true
+				}
+				return k.Type, "", true
+			}
+		}
+	}
+	if v, ok := a.opts.Defaults.lookup(path); ok && path != "" {
+		return valueType(v), "", true
+	}
+	return "", "", false
+}
+
+// sliceType answers true if the path is a slice, along
+// with the type of its elements, if it can be determined
+// from the first default element.
+func (a *argsParser) sliceType(path string) (string, bool) {
+	isSlice := false
+	if a.opts.Schema != nil {
+		p := strings.Split(path, pathSeparator)
+		for _, k := range a.opts.Schema.Keys {
+			kp := strings.Split(strings.Trim(k.Path, pathSeparator), pathSeparator)
+			if matchPath(kp, p) {
+				isSlice = k.Type == TypeSlice
+				break
+			}
+		}
+	}
+	if v, ok := a.opts.Defaults.lookup(path); ok && path != "" {
+		list, ok := v.([]any)
+		if ok && len(list) > 0 {
+			return valueType(list[0]), true
+		}
+		isSlice = isSlice || ok
+	}
+	return "", isSlice
+}
+
+// help writes a description of every known path.
+func (a *argsParser) help() {
+	type entry struct {
+		typ  string
+		doc  string
+		dflt any
+	}
+	entries := make(map[string]entry)
+	if a.opts.Defaults.t != nil {
+		for _, path := range sourcePaths(a.opts.Defaults.t, "") {
+			v, _ := a.opts.Defaults.lookup(path)
+			entries[path] = entry{typ: valueType(v), dflt: v}
+		}
+	}
+	if a.opts.Schema != nil {
+		for _, k := range a.opts.Schema.Keys {
+			path := strings.Trim(k.Path, pathSeparator)
+			if strings.Contains(path, "*") {
+				continue
+			}
+			e := entries[path]
+			e.typ, e.doc = cmp.Or(k.Type, e.typ), k.Doc
+			if k.Default != nil {
+				e.dflt = k.Default
+			}
+			entries[path] = e
+		}
+	}
+	w := a.opts.Output
+	if w == nil {
+		w = os.Stdout
+	}
+	fmt.Fprintln(w, "Flags:")
+	fmt.Fprintln(w, "  --help\n    \tShow this help.")
+	for _, path := range slices.Sorted(maps.Keys(entries)) {
+		e := entries[path]
+		fmt.Fprintf(w, "  --%v", path)
+		if e.typ != "" && e.typ != TypeBool {
+			fmt.Fprintf(w, " %v", e.typ)
+		}
+		desc := e.doc
+		if e.dflt != nil {
+			desc = strings.TrimSpace(fmt.Sprintf("%v (default %v)", desc, formatValue(e.dflt)))
+		}
+		if desc != "" {
+			fmt.Fprint(w, "\n    \t", desc)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// parseArg converts the flag value to the type.
+func parseArg(value, typ string) (any, error) {
+	switch typ {
+	case TypeBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("has value \"%v\" but wants a bool", value)
+		}
+		return b, nil
+	case TypeInt, TypeFloat:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || (typ == TypeInt && f != math.Trunc(f)) {
+			return nil, fmt.Errorf("has value \"%v\" but wants a %v", value, typ)
+		}
+		return f, nil
+	case TypeString:
+		return value, nil
+	case TypeMap:
+		return nil, fmt.Errorf("can't set a map")
+	default:
+		var v any
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			return value, nil
+		}
+		return v, nil
+	}
+}
+
+// valueType answers the schema type of a settings value.
+func valueType(v any) string {
+	switch v.(type) {
+	case bool:
+		return TypeBool
+	case float64:
+		// Defaults can't distinguish ints from floats.
+		return TypeFloat
+	case string:
+		return TypeString
+	case []any:
+		return TypeSlice
+	case map[string]any:
+		return TypeMap
+	}
+	return ""
+}
+
+// argPath converts a flag name to a settings path.
+func argPath(name string) string {
+	return strings.Trim(strings.ReplaceAll(name, ".", pathSeparator), pathSeparator)
+}
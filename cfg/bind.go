@@ -0,0 +1,243 @@
+package cfg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Bind assigns the settings at path to the struct pointed to
+// by dst. Fields are bound with a cfg tag that supplies a path,
+// relative to the parent, and optional default and required flags:
+//
+//	type Server struct {
+//		Host  string   `cfg:"host,required"`
+//		Port  int      `cfg:"net/port,default=8080"`
+//		Users []User   `cfg:"users"`
+//		Tags  []string `cfg:"tags,default=[\"a\", \"b\"]"`
+//	}
+//
+// Values are converted with the same rules as the getters (Bool,
+// Int64, etc.), and types with a converter are converted as in
+// Get(). Nested structs, pointers, slices and string-keyed maps
+// are followed, and an embedded struct without a tag is bound at
+// the same path as its parent. Fields without a tag are ignored.
+//
+// The default must be the last option, and consumes the rest of
+// the tag. It is decoded as JSON when possible, otherwise it is
+// used as a string.
+//
+// All missing required keys and conversion errors are reported
+// together in the answered error.
+func Bind(s Settings, path string, dst any) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cfg.Bind: dst must be a pointer to a struct, not %T", dst)
+	}
+	raw, _ := s.lookup(path)
+	b := &binder{}
+	b.bindStruct(strings.Trim(path, pathSeparator), raw, v.Elem())
+	if len(b.missing) > 0 {
+		b.errs = append([]error{fmt.Errorf("missing required keys: %v", strings.Join(b.missing, ", "))}, b.errs...)
+	}
+	if len(b.errs) > 0 {
+		return fmt.Errorf("cfg.Bind: %w", errors.Join(b.errs...))
+	}
+	return nil
+}
+
+// binder walks a struct, assigning values from a raw settings tree.
+type binder struct {
+	missing []string
+	errs    []error
+}
+
+func (b *binder) bindStruct(base string, raw any, v reflect.Value) {
+	src := Settings{}
+	if m, ok := raw.(map[string]any); ok {
+		src.t = m
+	}
+	t := v.Type()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		fv := v.Field(i)
+		tag, hasTag := f.Tag.Lookup(bindTagName)
+		if !hasTag && f.Anonymous && fv.Kind() == reflect.Struct {
+			b.bindStruct(base, raw, fv)
+			continue
+		}
+		if !hasTag || tag == "-" || !f.IsExported() {
+			continue
+		}
+		opts := parseBindTag(tag)
+		path := joinPath(base, opts.path)
+		fraw, ok := src.lookup(opts.path)
+		if !ok && opts.hasDefault {
+			fraw, ok = opts.defaultValue(fv.Kind()), true
+		}
+		if !ok {
+			if opts.required {
+				b.missing = append(b.missing, path)
+			}
+			// Keep walking nested structs, so their required
+			// keys are reported.
+			if fv.Kind() == reflect.Struct {
+				b.bindStruct(path, nil, fv)
+			}
+			continue
+		}
+		b.bindValue(path, fraw, fv)
+	}
+}
+
+func (b *binder) bindValue(path string, raw any, v reflect.Value) {
+	if _, ok := converterFor(v.Type()); ok {
+		cv, ok := convert(raw, v.Type())
+		if !ok {
+			b.addError(path, raw, v)
+			return
+		}
+		v.Set(cv)
+		return
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		if _, ok := raw.(map[string]any); !ok {
+			b.addError(path, raw, v)
+			return
+		}
+		b.bindStruct(path, raw, v)
+	case reflect.Pointer:
+		elem := reflect.New(v.Type().Elem())
+		b.bindValue(path, raw, elem.Elem())
+		v.Set(elem)
+	case reflect.Slice:
+		list, ok := raw.([]any)
+		if !ok {
+			b.addError(path, raw, v)
+			return
+		}
+		sl := reflect.MakeSlice(v.Type(), len(list), len(list))
+		for i, item := range list {
+			b.bindValue(joinPath(path, strconv.Itoa(i)), item, sl.Index(i))
+		}
+		v.Set(sl)
+	case reflect.Map:
+		m, ok := raw.(map[string]any)
+		if !ok || v.Type().Key().Kind() != reflect.String {
+			b.addError(path, raw, v)
+			return
+		}
+		dst := reflect.MakeMapWithSize(v.Type(), len(m))
+		for k, item := range m {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			b.bindValue(joinPath(path, k), item, elem)
+			dst.SetMapIndex(reflect.ValueOf(k).Convert(v.Type().Key()), elem)
+		}
+		v.Set(dst)
+	case reflect.Interface:
+		if raw == nil {
+			return
+		} else if !reflect.TypeOf(raw).AssignableTo(v.Type()) {
+			b.addError(path, raw, v)
+			return
+		}
+		v.Set(reflect.ValueOf(raw))
+	default:
+		if !setLeaf(raw, v) {
+			b.addError(path, raw, v)
+		}
+	}
+}
+
+func (b *binder) addError(path string, raw any, v reflect.Value) {
+	b.errs = append(b.errs, fmt.Errorf("%v: can't assign %T to %v", path, raw, v.Type()))
+}
+
+// setLeaf assigns a raw value to a scalar, using the
+// leaf getters so conversion matches the Settings API.
+func setLeaf(raw any, v reflect.Value) bool {
+	s := Settings{t: tree{leafKey: raw}}
+	switch v.Kind() {
+	case reflect.Bool:
+		if b, ok := leafBool(s, leafKey); ok {
+			v.SetBool(b)
+			return true
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := leafInt64(s, leafKey); ok && !v.OverflowInt(i) {
+			v.SetInt(i)
+			return true
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if i, ok := leafInt64(s, leafKey); ok && i >= 0 && !v.OverflowUint(uint64(i)) {
+			v.SetUint(uint64(i))
+			return true
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, ok := leafFloat64(s, leafKey); ok && !v.OverflowFloat(f) {
+			v.SetFloat(f)
+			return true
+		}
+	case reflect.String:
+		if str, ok := leafString(s, leafKey); ok {
+			v.SetString(str)
+			return true
+		}
+	}
+	return false
+}
+
+type bindTag struct {
+	path         string
+	required     bool
+	hasDefault   bool
+	defaultValue func(reflect.Kind) any
+}
+
+func parseBindTag(tag string) bindTag {
+	opts := bindTag{}
+	path, rest, _ := strings.Cut(tag, ",")
+	opts.path = strings.TrimSpace(path)
+	for rest != "" {
+		rest = strings.TrimLeft(rest, " ")
+		if value, ok := strings.CutPrefix(rest, "default="); ok {
+			opts.hasDefault = true
+			opts.defaultValue = func(kind reflect.Kind) any {
+				if kind == reflect.String {
+					return value
+				}
+				var v any
+				if err := json.Unmarshal([]byte(value), &v); err != nil {
+					return value
+				}
+				return v
+			}
+			break
+		}
+		var opt string
+		opt, rest, _ = strings.Cut(rest, ",")
+		if strings.TrimSpace(opt) == "required" {
+			opts.required = true
+		}
+	}
+	return opts
+}
+
+func joinPath(base, path string) string {
+	path = strings.Trim(path, pathSeparator)
+	if base == "" {
+		return path
+	} else if path == "" {
+		return base
+	}
+	return base + pathSeparator + path
+}
+
+const (
+	bindTagName = "cfg"
+	leafKey     = "v"
+)
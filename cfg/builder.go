@@ -1,6 +1,10 @@
 package cfg
 
-import "reflect"
+import (
+	"io/fs"
+	"slices"
+	"strings"
+)
 
 // Builder is used to add new settings during a Settings construction.
 type Builder interface {
@@ -17,6 +21,18 @@ type Builder interface {
 
 type _builder struct {
 	t tree
+	// sources are the files read by the options, used
+	// by the Watcher to detect changes.
+	sources []watchSource
+	// src is the source of each value, by path. index and
+	// source describe the option currently being run.
+	src    map[string]Source
+	index  int
+	source sourceFunc
+	// private are the private paths, and key decrypts
+	// encrypted values once all options have run.
+	private []string
+	key     []byte
 }
 
 func (b *_builder) Settings() map[string]any {
@@ -27,21 +43,74 @@ func (b *_builder) NewSettings() map[string]any {
 	return make(map[string]any)
 }
 
+// addSource records a file pattern read by an option.
+func (b *_builder) addSource(fsys fs.FS, pattern string) {
+	b.sources = append(b.sources, watchSource{fsys: fsys, pattern: pattern})
+}
+
 func (b *_builder) AddSettings(m map[string]any) {
 	if len(m) > 0 {
+		b.record(b.t, m, nil)
 		mergeKeys(b.t, m)
 	}
 }
 
+// startOption prepares to run the option at index.
+func (b *_builder) startOption(index int) {
+	b.index = index
+	b.source = nil
+}
+
+// record assigns the current source to every value in right
+// that will replace a value in left when they're merged.
+func (b *_builder) record(left, right tree, keys []string) {
+	if b.src == nil {
+		b.src = make(map[string]Source)
+	}
+	for k, rightVal := range right {
+		path := slices.Concat(keys, []string{k})
+		leftMap, leftIsMap := left[k].(map[string]any)
+		if rightMap, ok := rightVal.(map[string]any); ok && (len(rightMap) > 0 || leftIsMap) {
+			if _, present := left[k]; present && !leftIsMap {
+				b.forget(path)
+			}
+			b.record(leftMap, rightMap, path)
+			continue
+		}
+		if leftIsMap {
+			b.forget(path)
+		}
+		src := Source{Kind: SourceOption, Index: b.index}
+		if b.source != nil {
+			src = b.source(path)
+			src.Index = b.index
+		}
+		b.src[strings.Join(path, pathSeparator)] = src
+	}
+}
+
+// forget removes the source of keys and everything below it.
+func (b *_builder) forget(keys []string) {
+	path := strings.Join(keys, pathSeparator)
+	prefix := path + pathSeparator
+	for k := range b.src {
+		if k == path || strings.HasPrefix(k, prefix) {
+			delete(b.src, k)
+		}
+	}
+}
+
 // Given two maps, recursively merge right into left. Adapted from
 // https://stackoverflow.com/questions/22621754/how-can-i-merge-two-maps-in-go
 func mergeKeys(left, right tree) tree {
 	for key, rightVal := range right {
 		if leftVal, present := left[key]; present {
-			// Key exists. If my new value is a map, recurse.
-			// If it's not, replace.
-			if reflect.TypeOf(rightVal).Kind() == reflect.Map {
-				left[key] = mergeKeys(leftVal.(tree), rightVal.(tree))
+			// Key exists. If both values are maps, recurse.
+			// If not, replace.
+			leftMap, lok := leftVal.(tree)
+			rightMap, rok := rightVal.(tree)
+			if lok && rok {
+				left[key] = mergeKeys(leftMap, rightMap)
 			} else {
 				left[key] = rightVal
 			}
@@ -1,15 +1,25 @@
 package cfg
 
 import (
+	"cmp"
 	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/hackborn/onefunc/jacl"
 	"github.com/hackborn/onefunc/math/geo"
+	"github.com/hackborn/onefunc/msg"
 )
 
 func TestMain(m *testing.M) {
@@ -18,6 +28,161 @@ func TestMain(m *testing.M) {
 	os.Exit(code)
 }
 
+// ---------------------------------------------------------
+// TEST-ARGS
+func TestArgs(t *testing.T) {
+	defaults, _ := NewSettings(WithString(`{"net": {"port": 80, "host": "h"}, "tags": ["a"], "ratio": 0.5, "debug": false}`))
+	schema := &Schema{Keys: []SchemaKey{
+		{Path: "net/port", Type: TypeInt, Doc: "Port to listen on.", Default: 80.0},
+		{Path: "net/host", Type: TypeString},
+		{Path: "verbose", Type: TypeBool},
+		{Path: "ids", Type: TypeSlice},
+	}}
+	table := []struct {
+		args           []string
+		opts           ArgsOpts
+		cmp            []string
+		wantPositional []string
+		wantErr        error
+	}{
+		{[]string{"--net.port=8080", "--net/host", "localhost", "--debug", "--ratio", "2"}, ArgsOpts{Defaults: defaults}, []string{`net/port=8080`, `net/host=localhost`, `debug=true`, `ratio=2`}, nil, nil},
+		{[]string{"--tags", "b", "--tags=c"}, ArgsOpts{Defaults: defaults}, []string{`tags/{count}=2`, `tags/0=b`, `tags/1=c`}, nil, nil},
+		{[]string{"--net.port", "9", "--verbose", "--ids", "1", "--ids", "x"}, ArgsOpts{Schema: schema}, []string{`net/port=9`, `verbose=true`, `ids/{count}=2`, `ids/0=1`, `ids/1=x`}, nil, nil},
+		{[]string{"--a.b", "10", "--c", "--d", "x", "--d", "y", "--d", "z"}, ArgsOpts{}, []string{`a/b=10`, `c=true`, `d/{count}=3`, `d/2=z`}, nil, nil},
+		{[]string{"file1", "--n", "1", "--", "--file2"}, ArgsOpts{Positional: &[]string{}}, []string{`n=1`}, []string{"file1", "--file2"}, nil},
+		// Errors
+		{[]string{"--net.port=high"}, ArgsOpts{Schema: schema}, nil, nil, fmt.Errorf("flag --net.port: has value \"high\" but wants a int")},
+		{[]string{"--net.port=1.5"}, ArgsOpts{Schema: schema}, nil, nil, fmt.Errorf("wants a int")},
+		{[]string{"--other=1"}, ArgsOpts{Schema: schema}, nil, nil, fmt.Errorf("flag --other: unknown setting")},
+		{[]string{"--net.host"}, ArgsOpts{Schema: schema}, nil, nil, fmt.Errorf("flag --net.host: needs a value")},
+		{[]string{"--net", "x"}, ArgsOpts{Defaults: defaults}, nil, nil, fmt.Errorf("can't set a map")},
+		{[]string{"file"}, ArgsOpts{}, nil, nil, fmt.Errorf("unexpected argument")},
+		{[]string{"--tags", "a", "--tags", "b", "--tags.k", "x"}, ArgsOpts{}, nil, nil, fmt.Errorf("flag --tags.k: conflicts with the value of --tags")},
+		{[]string{"--=x"}, ArgsOpts{}, nil, nil, fmt.Errorf("flag --=x: needs a setting path")},
+		{[]string{"--="}, ArgsOpts{}, nil, nil, fmt.Errorf("flag --=: needs a setting path")},
+		{[]string{"--a=1", "--a.b=2"}, ArgsOpts{}, nil, nil, fmt.Errorf("flag --a.b: conflicts with the value of --a")},
+		{[]string{"--a.b.c=1", "--a.b=2"}, ArgsOpts{}, nil, nil, fmt.Errorf("flag --a.b: conflicts with the settings below it")},
+		{[]string{"--a.b=1", "--a=2", "--a=3"}, ArgsOpts{}, nil, nil, fmt.Errorf("flag --a: conflicts with the settings below it")},
+	}
+	for i, v := range table {
+		s, haveErr := NewSettings(WithArgs(v.args, v.opts))
+		if err := jacl.RunErr(haveErr, v.wantErr); err != nil {
+			t.Fatalf("TestArgs %v %v", i, err)
+		} else if haveErr != nil {
+			continue
+		}
+		if err := jacl.Run(s.t, v.cmp...); err != nil {
+			t.Fatalf("TestArgs %v %v", i, err)
+		} else if v.opts.Positional != nil && !reflect.DeepEqual(*v.opts.Positional, v.wantPositional) {
+			t.Fatalf("TestArgs %v has positional %v but wants %v", i, *v.opts.Positional, v.wantPositional)
+		}
+	}
+
+	// Help
+	sb := &strings.Builder{}
+	_, err := NewSettings(WithArgs([]string{"--help"}, ArgsOpts{Schema: schema, Defaults: defaults, Output: sb}))
+	want := `Flags:
+  --help
+    	Show this help.
+  --debug
+    	(default false)
+  --ids slice
+  --net/host string
+    	(default "h")
+  --net/port int
+    	Port to listen on. (default 80)
+  --ratio float
+    	(default 0.5)
+  --tags slice
+    	(default ["a"])
+  --verbose
+`
+	if !errors.Is(err, ErrHelp) {
+		t.Fatalf("TestArgs help has error %v", err)
+	} else if sb.String() != want {
+		t.Fatalf("TestArgs help has\n%v\nbut wants\n%v", sb.String(), want)
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-BIND
+func TestBind(t *testing.T) {
+	type user struct {
+		Name  string `cfg:"name,required"`
+		Admin bool   `cfg:"admin"`
+	}
+	type limits struct {
+		Max int64 `cfg:"max"`
+	}
+	type server struct {
+		limits
+		Host    string            `cfg:"host,required"`
+		Port    int               `cfg:"net/port,default=8080"`
+		Ratio   float32           `cfg:"ratio"`
+		Tags    []string          `cfg:"tags,default=[\"a\", \"b\"]"`
+		Users   []user            `cfg:"users"`
+		Owner   *user             `cfg:"owner"`
+		Labels  map[string]string `cfg:"labels"`
+		Note    string            `cfg:"note,default=x, y"`
+		Extra   any               `cfg:"extra"`
+		Reader  io.Reader         `cfg:"reader"`
+		Ignored string
+	}
+	table := []struct {
+		settings string
+		path     string
+		cmp      []string
+		wantErr  error
+	}{
+		{`{"host": "h"}`, "", []string{`Host=h`, `Port=8080`, `Tags/{count}=2`, `Tags/1=b`, `Users/{count}=0`, `Note="x, y"`}, nil},
+		{`{"s": {"host": "h", "net": {"port": 9}, "max": 3}}`, "s", []string{`Host=h`, `Port=9`, `Max=3`}, nil},
+		{`{"host": "h", "ratio": 0.5, "tags": ["z"]}`, "", []string{`Ratio=0.5`, `Tags/{count}=1`, `Tags/0=z`}, nil},
+		{`{"host": "h", "users": [{"name": "a", "admin": true}, {"name": "b", "admin": "t"}]}`, "", []string{`Users/{count}=2`, `Users/0/Name=a`, `Users/0/Admin=true`, `Users/1/Admin=true`}, nil},
+		{`{"host": "h", "owner": {"name": "o"}, "labels": {"k": "v"}}`, "", []string{`Owner/Name=o`, `Labels/k=v`}, nil},
+		{`{"host": "h", "Ignored": "no"}`, "", []string{`Ignored=""`}, nil},
+		{`{"host": "h", "extra": "e"}`, "", []string{`Extra=e`, `Reader/{nil}=true`}, nil},
+		// Errors
+		{`{}`, "", nil, fmt.Errorf("missing required host")},
+		{`{"host": "h", "users": [{"admin": true}, {}]}`, "", nil, fmt.Errorf("missing required users/0/name, users/1/name")},
+		{`{"host": "h", "net": {"port": "high"}}`, "", nil, fmt.Errorf("can't assign")},
+		{`{"host": "h", "users": {"name": "a"}}`, "", nil, fmt.Errorf("can't assign")},
+		{`{"host": "h", "reader": "r"}`, "", nil, fmt.Errorf("reader: can't assign string to io.Reader")},
+	}
+	for i, v := range table {
+		s, err := NewSettings(WithString(v.settings))
+		if err != nil {
+			t.Fatalf("TestBind %v settings err %v", i, err)
+		}
+		have := server{}
+		haveErr := Bind(s, v.path, &have)
+		if err := jacl.RunErr(haveErr, v.wantErr); err != nil {
+			t.Fatalf("TestBind %v %v", i, err.Error())
+		} else if haveErr == nil {
+			if err = jacl.Run(have, v.cmp...); err != nil {
+				t.Fatalf("TestBind %v comparison error: %v", i, err)
+			}
+		}
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-BIND-MISSING
+func TestBindMissing(t *testing.T) {
+	type inner struct {
+		A string `cfg:"a,required"`
+	}
+	type outer struct {
+		B     string `cfg:"b, required"`
+		Inner inner  `cfg:"inner"`
+	}
+	s, _ := NewSettings(WithString(`{}`))
+	err := Bind(s, "", &outer{})
+	want := "cfg.Bind: missing required keys: b, inner/a"
+	if err == nil || err.Error() != want {
+		t.Fatalf("TestBindMissing has \"%v\" but wants \"%v\"", err, want)
+	}
+}
+
 // ---------------------------------------------------------
 // TEST-BOOL
 func TestBool(t *testing.T) {
@@ -54,6 +219,222 @@ func TestBool(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------
+// TEST-DECODE
+func TestDecode(t *testing.T) {
+	table := []struct {
+		decode  Decoder
+		dat     string
+		cmp     []string
+		wantErr error
+	}{
+		// JSONC
+		{DecodeJsonc, `{"a": 1, "b": [1, 2,],}`, []string{`a=1`, `b/{count}=2`}, nil},
+		{DecodeJsonc, "{\n// line\n\"a\": \"x // y\", /* block, */ \"b\": \"/*z*/\"\n}", []string{`a="x // y"`, `b="/*z*/"`}, nil},
+		{DecodeJsonc, `{"a": "q\", ]"}`, []string{`a="q'', ]"`}, nil},
+		// INI
+		{DecodeIni, "# c\nname = \"n\" # c\npath = C:/a b ; c\ncount = 10\nok = true\ntags = [\"a\", 2, [true],]\n", []string{`name=n`, `path="C:/a b"`, `count=10`, `ok=true`, `tags/{count}=3`, `tags/1=2`, `tags/2/0=true`}, nil},
+		{DecodeIni, "[run]\nspeed = 2.5\nfast.x = 'lit$x'\n[run.sub]\npt = { x = 1, y = \"b\" }", []string{`run/speed=2.5`, `run/fast/x="lit$x"`, `run/sub/pt/x=1`, `run/sub/pt/y=b`}, nil},
+		{DecodeIni, "[[s]]\nh = a\n[[s]]\nh = b\n[s.opt]\nv = 1", []string{`s/{count}=2`, `s/0/h=a`, `s/1/h=b`, `s/1/opt/v=1`}, nil},
+		// Env
+		{DecodeEnv, "# c\nA=1\nexport B = two words # c\nC=\"x\\\"y\"\nD='$z'\nE=", []string{`A="1"`, `B="two words"`, `C="x''y"`, `D="$z"`, `E=""`}, nil},
+		// Errors
+		{DecodeJsonc, `{"a": 1 /* open`, nil, fmt.Errorf("unexpected end")},
+		{DecodeIni, "a = 1\na = 2", nil, fmt.Errorf("line 2: duplicate key")},
+		{DecodeIni, "a = [1, 2", nil, fmt.Errorf("line 1: unterminated array")},
+		{DecodeIni, "a = 1\n[a]", nil, fmt.Errorf("line 2: key")},
+		{DecodeIni, "[a", nil, fmt.Errorf("line 1: bad section")},
+		{DecodeIni, "tags = []\n[tags.child]\na = 1\n", nil, fmt.Errorf("line 2: key \"tags\" is not a table")},
+		{DecodeEnv, "A B=1", nil, fmt.Errorf("line 1: bad assignment")},
+	}
+	for i, v := range table {
+		have, haveErr := v.decode([]byte(v.dat))
+		if err := jacl.RunErr(haveErr, v.wantErr); err != nil {
+			t.Fatalf("TestDecode %v %v", i, err)
+		} else if haveErr == nil {
+			if err := jacl.Run(have, v.cmp...); err != nil {
+				t.Fatalf("TestDecode %v %v", i, err)
+			}
+		}
+	}
+
+	// Decoders are selected by extension.
+	fsys := fstest.MapFS{
+		"a.jsonc":  {Data: []byte("{\"a\": 1, // c\n}")},
+		"b.ini":    {Data: []byte("b = 2")},
+		"c.env":    {Data: []byte("C=3")},
+		"d.config": {Data: []byte("{\"d\": 4}")},
+		"e.cfg":    {Data: []byte("e = 5")},
+	}
+	s, err := NewSettings(WithFS(fsys, "[a-d].*"), WithFSDecoder(fsys, "e.cfg", DecodeIni))
+	if err != nil {
+		t.Fatalf("TestDecode has error %v", err)
+	} else if err := jacl.Run(s.t, `a=1`, `b=2`, `C="3"`, `d=4`, `e=5`); err != nil {
+		t.Fatalf("TestDecode %v", err)
+	}
+
+	// JSON files are strict.
+	fsys = fstest.MapFS{"a.json": {Data: []byte("{\"a\": 1, // c\n}")}}
+	_, err = NewSettings(WithFS(fsys, "a.json"))
+	if err := jacl.RunErr(err, fmt.Errorf("a.json: invalid character")); err != nil {
+		t.Fatalf("TestDecode %v", err)
+	}
+
+	// Files that fail to decode are skipped, not processed.
+	fsys = fstest.MapFS{"a.ini": {Data: []byte("a = [1")}}
+	process := func(m map[string]any) map[string]any {
+		m["p"] = 1
+		return m
+	}
+	_, err = NewSettings(WithFS(fsys, "a.ini", process))
+	if err := jacl.RunErr(err, fmt.Errorf("a.ini: line 1: unterminated array")); err != nil {
+		t.Fatalf("TestDecode %v", err)
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-DIFF
+func TestDiff(t *testing.T) {
+	table := []struct {
+		a    string
+		b    string
+		want SettingsDiff
+	}{
+		{`{}`, `{}`, SettingsDiff{}},
+		{`{"a": 1, "m": {"x": 1}}`, `{"a": 1, "m": {"x": 1}}`, SettingsDiff{}},
+		{`{"a": 1}`, `{"a": 1, "m": {"x": 1, "y": 2}}`, SettingsDiff{Added: []string{"m/x", "m/y"}}},
+		{`{"a": 1, "b": 2}`, `{"b": 2}`, SettingsDiff{Removed: []string{"a"}}},
+		{`{"a": 1, "m": {"x": 1}}`, `{"a": "1", "m": {"x": 2}}`, SettingsDiff{Changed: []string{"a", "m/x"}}},
+		{`{"l": [1, 2, 3]}`, `{"l": [1, 5]}`, SettingsDiff{Removed: []string{"l/2"}, Changed: []string{"l/1"}}},
+	}
+	for i, v := range table {
+		a, err1 := NewSettings(WithString(v.a))
+		b, err2 := NewSettings(WithString(v.b))
+		if err := cmp.Or(err1, err2); err != nil {
+			t.Fatalf("TestDiff %v has error %v", i, err)
+		}
+		have := Diff(a, b)
+		if !reflect.DeepEqual(have, v.want) {
+			t.Fatalf("TestDiff %v has %#v but wants %#v", i, have, v.want)
+		} else if have.Empty() != (len(v.a) == len(v.b) && v.a == v.b) {
+			t.Fatalf("TestDiff %v has wrong Empty()", i)
+		}
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-INTERPOLATION
+func TestInterpolation(t *testing.T) {
+	table := []struct {
+		settings string
+		cmp      []string
+		wantErr  error
+	}{
+		{`{"a": "x"}`, []string{`a=x`}, nil},
+		{`{"dir": "/base", "cache": "${dir}/cache", "log": "${cache}/log"}`, []string{`cache="/base/cache"`, `log="/base/cache/log"`}, nil},
+		{`{"run": {"count": 10}, "n": "${run/count}", "s": "n=${run/count}", "m": "${run}"}`, []string{`n=10`, `s="n=10"`, `m/count=10`}, nil},
+		{`{"l": ["${a}", "b"], "a": "${l/1}!"}`, []string{`l/0="b!"`, `a="b!"`}, nil},
+		{`{"e": "${env:CFG_TESTDATA_A}-${env:CFG_TESTDATA_B}", "x": "$${a}", "y": "$5"}`, []string{`e="ant-bear"`, `x="${a}"`, `y="$5"`}, nil},
+		// Errors
+		{`{"a": "${b}", "b": "${c}", "c": "${a}"}`, nil, fmt.Errorf("reference cycle a -> b -> c -> a")},
+		{`{"a": "${a}"}`, nil, fmt.Errorf("reference cycle a -> a")},
+		{`{"a": "${b}"}`, nil, fmt.Errorf("a: no setting for reference \"b\"")},
+		{`{"a": "${b"}`, nil, fmt.Errorf("a: unterminated variable")},
+		{`{"a": "${env:CFG_TESTDATA_MISSING}"}`, nil, fmt.Errorf("a: env var \"CFG_TESTDATA_MISSING\" is not set")},
+		{`{"a": "x${m}", "m": {}}`, nil, fmt.Errorf("a: variable \"${m}\" is a map")},
+	}
+	for i, v := range table {
+		s, haveErr := NewSettings(WithString(v.settings), WithInterpolation())
+		if err := jacl.RunErr(haveErr, v.wantErr); err != nil {
+			t.Fatalf("TestInterpolation %v %v", i, err)
+		} else if haveErr == nil {
+			if err := jacl.Run(s.t, v.cmp...); err != nil {
+				t.Fatalf("TestInterpolation %v %v", i, err)
+			}
+		}
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-GET
+func TestGet(t *testing.T) {
+	type level int
+	RegisterConverter(func(v any) (level, bool) {
+		str, ok := v.(string)
+		return level(len(str)), ok
+	})
+	s, err := NewSettings(WithString(`{
+		"d1": "1.5s", "d2": 2, "b1": "64MiB", "b2": "1.5 kb", "b3": 512, "b4": "2XB",
+		"t1": "2024-02-03T04:05:06Z", "t2": "2024-02-03", "t3": 60,
+		"i": [1, 2, 3], "f": [1.5, 2], "s": ["a", "b"], "bad": [1, "a"],
+		"m": {"a": "x", "b": 2, "c": true}, "pt1": {"x": 1, "Y": 2}, "pt2": [3, 4],
+		"rng": {"min": 1, "max": 5}, "lvl": "hhh", "n": 7, "str": "s"}`),
+		WithMap(map[string]any{"d3": 3, "d4": 5 * time.Millisecond, "t4": int64(60), "t5": time.Date(2024, 2, 3, 0, 0, 0, 0, time.UTC)}))
+	if err != nil {
+		t.Fatalf("TestGet has error %v", err)
+	}
+	table := []struct {
+		get    func(Settings) (any, bool)
+		want   any
+		wantOk bool
+	}{
+		{func(s Settings) (any, bool) { return Get[time.Duration](s, "d1") }, 1500 * time.Millisecond, true},
+		{func(s Settings) (any, bool) { return Get[time.Duration](s, "d2") }, 2 * time.Second, true},
+		{func(s Settings) (any, bool) { return Get[time.Duration](s, "d3") }, 3 * time.Second, true},
+		{func(s Settings) (any, bool) { return Get[time.Duration](s, "d4") }, 5 * time.Millisecond, true},
+		{func(s Settings) (any, bool) { return Get[time.Duration](s, "str") }, time.Duration(0), false},
+		{func(s Settings) (any, bool) { return Get[ByteSize](s, "b1") }, ByteSize(64 << 20), true},
+		{func(s Settings) (any, bool) { return Get[ByteSize](s, "b2") }, ByteSize(1500), true},
+		{func(s Settings) (any, bool) { return Get[ByteSize](s, "b3") }, ByteSize(512), true},
+		{func(s Settings) (any, bool) { return Get[ByteSize](s, "b4") }, ByteSize(0), false},
+		{func(s Settings) (any, bool) { return Get[time.Time](s, "t1") }, time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC), true},
+		{func(s Settings) (any, bool) { return Get[time.Time](s, "t2") }, time.Date(2024, 2, 3, 0, 0, 0, 0, time.UTC), true},
+		{func(s Settings) (any, bool) { return Get[time.Time](s, "t3") }, time.Unix(60, 0).UTC(), true},
+		{func(s Settings) (any, bool) { return Get[time.Time](s, "t4") }, time.Unix(60, 0).UTC(), true},
+		{func(s Settings) (any, bool) { return Get[time.Time](s, "t5") }, time.Date(2024, 2, 3, 0, 0, 0, 0, time.UTC), true},
+		{func(s Settings) (any, bool) { return Get[[]int64](s, "i") }, []int64{1, 2, 3}, true},
+		{func(s Settings) (any, bool) { return Get[[]float64](s, "f") }, []float64{1.5, 2}, true},
+		{func(s Settings) (any, bool) { return Get[[]string](s, "s") }, []string{"a", "b"}, true},
+		{func(s Settings) (any, bool) { return Get[[]int64](s, "bad") }, []int64(nil), false},
+		{func(s Settings) (any, bool) { return Get[map[string]string](s, "m") }, map[string]string{"a": "x", "b": "2", "c": "true"}, true},
+		{func(s Settings) (any, bool) { return Get[geo.PtF](s, "pt1") }, geo.PtF{X: 1, Y: 2}, true},
+		{func(s Settings) (any, bool) { return Get[geo.PtF](s, "pt2") }, geo.PtF{X: 3, Y: 4}, true},
+		{func(s Settings) (any, bool) { return Get[geo.RngF](s, "rng") }, geo.RngF{Min: 1, Max: 5}, true},
+		{func(s Settings) (any, bool) { return Get[geo.RngF](s, "m") }, geo.RngF{}, false},
+		{func(s Settings) (any, bool) { return Get[level](s, "lvl") }, level(3), true},
+		{func(s Settings) (any, bool) { return Get[int](s, "n") }, 7, true},
+		{func(s Settings) (any, bool) { return Get[int](s, "i/1") }, 2, true},
+		{func(s Settings) (any, bool) { return Get[string](s, "str") }, "s", true},
+		{func(s Settings) (any, bool) { return Get[any](s, "n") }, 7.0, true},
+		{func(s Settings) (any, bool) { return Get[int](s, "missing") }, 0, false},
+		{func(s Settings) (any, bool) { return MustGet(s, "missing", 5*time.Second), true }, 5 * time.Second, true},
+		{func(s Settings) (any, bool) { return MustGet(s, "b1", ByteSize(0)).String(), true }, "64MiB", true},
+	}
+	for i, v := range table {
+		have, haveOk := v.get(s)
+		if haveOk != v.wantOk {
+			t.Fatalf("TestGet %v has ok %v but wants %v", i, haveOk, v.wantOk)
+		} else if haveOk && !reflect.DeepEqual(have, v.want) {
+			t.Fatalf("TestGet %v has %#v but wants %#v", i, have, v.want)
+		}
+	}
+
+	// Modifying a map or slice result doesn't modify the settings.
+	before, _ := s.asJson()
+	if m, ok := Get[map[string]any](s, "m"); ok {
+		m["a"] = "changed"
+	}
+	if l, ok := Get[[]any](s, "i"); ok {
+		l[0] = "changed"
+	}
+	if a, ok := Get[any](s, "m"); ok {
+		a.(map[string]any)["b"] = "changed"
+	}
+	if after, _ := s.asJson(); string(before) != string(after) {
+		t.Fatalf("TestGet modified the settings to %s", after)
+	}
+}
+
 // ---------------------------------------------------------
 // TEST-INT64
 func TestInt64(t *testing.T) {
@@ -88,6 +469,120 @@ func TestInt64(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------
+// TEST-MERGE
+func TestMerge(t *testing.T) {
+	table := []struct {
+		a        string
+		b        string
+		strategy MergeStrategy
+		cmp      []string
+	}{
+		{`{"a": 1, "m": {"x": 1, "l": [1]}}`, `{"b": 2, "m": {"y": 2, "l": [2]}}`, MergeDeep, []string{`a=1`, `b=2`, `m/x=1`, `m/y=2`, `m/l/{count}=1`, `m/l/0=2`}},
+		{`{"a": 1, "m": {"x": 1}}`, `{"m": {"y": 2}}`, MergeReplace, []string{`a=1`, `m/{count}=1`, `m/y=2`}},
+		{`{"m": {"x": 1, "l": [1]}}`, `{"m": {"l": [2, 3]}}`, MergeAppend, []string{`m/x=1`, `m/l/{count}=3`, `m/l/2=3`}},
+		{`{"a": 1, "m": {"x": 1}}`, `{"a": 2, "b": 2, "m": {"x": 2, "y": 2}}`, MergeKeep, []string{`a=1`, `b=2`, `m/x=1`, `m/y=2`}},
+		// Mismatched types
+		{`{"a": 1, "m": {"x": 1}}`, `{"a": {"b": 2}, "m": 3}`, MergeDeep, []string{`a/b=2`, `m=3`}},
+		{`{"a": 1}`, `{"a": {"b": 2}}`, MergeKeep, []string{`a=1`}},
+	}
+	for i, v := range table {
+		a, err1 := NewSettings(WithString(v.a))
+		b, err2 := NewSettings(WithString(v.b))
+		if err := cmp.Or(err1, err2); err != nil {
+			t.Fatalf("TestMerge %v has error %v", i, err)
+		}
+		before, _ := a.asJson()
+		have := a.Merge(b, v.strategy)
+		if err := jacl.Run(have.t, v.cmp...); err != nil {
+			t.Fatalf("TestMerge %v %v", i, err)
+		} else if after, _ := a.asJson(); string(before) != string(after) {
+			t.Fatalf("TestMerge %v modified the original to %s", i, after)
+		}
+	}
+
+	// Building used to panic when a map replaced a value.
+	s, err := NewSettings(WithString(`{"a": 1, "b": {"c": 1}}`), WithString(`{"a": {"b": 2}, "b": null}`))
+	if err != nil {
+		t.Fatalf("TestMerge has error %v", err)
+	} else if err := jacl.Run(s.t, `a/b=2`); err != nil {
+		t.Fatalf("TestMerge %v", err)
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-PRIVATE
+func TestPrivate(t *testing.T) {
+	key, err := NewKey()
+	if err != nil {
+		t.Fatalf("TestPrivate has error %v", err)
+	}
+	keyBytes, _ := hex.DecodeString(key)
+	enc, err := Encrypt(keyBytes, "s3cret")
+	if err != nil {
+		t.Fatalf("TestPrivate has error %v", err)
+	}
+	fsys := fstest.MapFS{
+		"app.key":  {Data: []byte(key + "\n")},
+		"bad.key":  {Data: []byte("zz")},
+		"app.json": {Data: []byte(`{"a": 1, "_token": "t", "auth": {"user": "u", "pass": "p", "_x": 1}, "db": {"pw": "` + enc + `"}, "users": [{"name": "n", "key": "k"}]}`)},
+	}
+	schema := Schema{Keys: []SchemaKey{{Path: "auth/pass", Private: true}, {Path: "users/*/key", Private: true}}}
+	table := []struct {
+		opts       []Option
+		wantJson   string
+		wantPublic string
+		cmp        []string
+		wantErr    error
+	}{
+		{[]Option{WithFS(fsys, "app.json"), WithPrivate(schema.PrivatePaths()...), WithKeyFile(fsys, "app.key")},
+			`{"a":1,"auth":{"user":"u"},"db":{},"users":[{"name":"n"}]}`,
+			`{"_token":"<redacted>","a":1,"auth":{"_x":"<redacted>","pass":"<redacted>","user":"u"},"db":{"pw":"<redacted>"},"users":[{"key":"<redacted>","name":"n"}]}`,
+			[]string{`db/pw=s3cret`, `auth/pass=p`, `_token=t`}, nil},
+		// The key can be supplied before the values.
+		{[]Option{WithKey(keyBytes), WithFS(fsys, "app.json")}, "", "", []string{`db/pw=s3cret`}, nil},
+		// Without a key, values are left encrypted.
+		{[]Option{WithFS(fsys, "app.json")}, "", "", []string{`db/pw="` + enc + `"`}, nil},
+		// Errors
+		{[]Option{WithFS(fsys, "app.json"), WithKey([]byte("0123456789abcdef"))}, "", "", nil, fmt.Errorf("db/pw: can't decrypt")},
+		{[]Option{WithKeyFile(fsys, "bad.key")}, "", "", nil, fmt.Errorf("cfg.WithKeyFile: bad.key")},
+		{[]Option{WithKey([]byte("short"))}, "", "", nil, fmt.Errorf("cfg.WithKey: crypto/aes: invalid key size")},
+	}
+	for i, v := range table {
+		s, haveErr := NewSettings(v.opts...)
+		if err := jacl.RunErr(haveErr, v.wantErr); err != nil {
+			t.Fatalf("TestPrivate %v %v", i, err)
+		} else if haveErr != nil {
+			continue
+		}
+		if err := jacl.Run(s.t, v.cmp...); err != nil {
+			t.Fatalf("TestPrivate %v %v", i, err)
+		}
+		if v.wantJson != "" {
+			have, _ := WriteJson(s)
+			if string(have) != v.wantJson {
+				t.Fatalf("TestPrivate %v has json %s but wants %v", i, have, v.wantJson)
+			}
+		}
+		if v.wantPublic != "" {
+			var sb strings.Builder
+			enc := json.NewEncoder(&sb)
+			enc.SetEscapeHTML(false)
+			enc.Encode(s.public(redactValue))
+			if have := strings.TrimSpace(sb.String()); have != v.wantPublic {
+				t.Fatalf("TestPrivate %v has redacted %s but wants %v", i, have, v.wantPublic)
+			}
+			if !strings.Contains(s.Explain(), "db/pw = \"<redacted>\"") {
+				t.Fatalf("TestPrivate %v has explain %v", i, s.Explain())
+			}
+			// Subsets keep their private paths.
+			if have, _ := WriteJson(s.Subset("auth")); string(have) != `{"user":"u"}` {
+				t.Fatalf("TestPrivate %v has subset json %s", i, have)
+			}
+		}
+	}
+}
+
 // ---------------------------------------------------------
 // TEST-RECT
 func TestRect(t *testing.T) {
@@ -110,6 +605,83 @@ func TestRect(t *testing.T) {
 	f(optC, "rect1", geo.Rect(4, 8, 4, 12))
 }
 
+// ---------------------------------------------------------
+// TEST-SCHEMA
+func TestSchema(t *testing.T) {
+	schema, err := ReadSchema(dataFs, "testdata/schema.json")
+	if err != nil {
+		t.Fatalf("TestSchema read err %v", err)
+	}
+	table := []struct {
+		settings string
+		want     string
+	}{
+		{`{"name": "a"}`, ``},
+		{`{"name": "a", "port": 80, "mode": "prod", "log": {"level": 2}}`, ``},
+		{`{"name": "a", "users": [{"name": "x"}], "extra": {"anything": {"goes": 1}}}`, ``},
+		// Errors
+		{`{}`, `name: missing required setting`},
+		{`{"name": 1}`, `name: has value 1 but wants a string`},
+		{`{"name": "a", "port": 0}`, `port: has value 0 but wants at least 1`},
+		{`{"name": "a", "port": 80.5}`, `port: has value 80.5 but wants an int`},
+		{`{"name": "a", "port": "80"}`, `port: has value "80" but wants an int`},
+		{`{"name": "a", "mode": "test"}`, `mode: has value "test" but wants one of ["dev","prod"]`},
+		{`{"name": "a", "log": {"level": 9}}`, `log/level: has value 9 but wants at most 3`},
+		{`{"name": "a", "users": [{"name": "x"}, {}, {"nmae": "y"}]}`, "users/1/name: missing required setting\nusers/2/name: missing required setting\nusers/2/nmae: unknown setting"},
+		{`{"name": "a", "prot": 80, "log": {"levle": 1}}`, "log/levle: unknown setting\nprot: unknown setting"},
+		{`{"port": 0, "extra": 1}`, "name: missing required setting\nport: has value 0 but wants at least 1\nextra: has value 1 but wants a map"},
+	}
+	for i, v := range table {
+		s, err := NewSettings(WithString(v.settings))
+		if err != nil {
+			t.Fatalf("TestSchema %v settings err %v", i, err)
+		}
+		have := ""
+		if err = s.Validate(schema); err != nil {
+			have = err.Error()
+		}
+		if have != v.want {
+			t.Fatalf("TestSchema %v has \"%v\" but wants \"%v\"", i, have, v.want)
+		}
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-SCHEMA-DEFAULTS
+func TestSchemaDefaults(t *testing.T) {
+	min := 1.0
+	schema := Schema{Keys: []SchemaKey{
+		{Path: "name", Type: TypeString, Required: true, Doc: "Name of the app."},
+		{Path: "net/port", Type: TypeInt, Min: &min, Default: 8080},
+		{Path: "net", Type: TypeMap, Doc: "Network settings."},
+		{Path: "mode", Type: TypeString, Enum: []any{"dev", "prod"}, Default: "dev"},
+		{Path: "users/*/name", Type: TypeString},
+	}}
+	want := `{
+  // (string, one of ["dev","prod"])
+  "mode": "dev",
+  // Name of the app.
+  // (string, required)
+  "name": "",
+  // Network settings.
+  // (map)
+  "net": {
+    // (int, min 1)
+    "port": 8080
+  }
+}
+`
+	if have := string(schema.DefaultConfig()); have != want {
+		t.Fatalf("TestSchemaDefaults has\n%v\nbut wants\n%v", have, want)
+	}
+	defaults := schema.Defaults()
+	if have := defaults.MustInt64("net/port", 0); have != 8080 {
+		t.Fatalf("TestSchemaDefaults has port %v but wants 8080", have)
+	} else if have := defaults.MustString("mode", ""); have != "dev" {
+		t.Fatalf("TestSchemaDefaults has mode %v but wants dev", have)
+	}
+}
+
 // ---------------------------------------------------------
 // TEST-SLICES
 func TestSlices(t *testing.T) {
@@ -136,6 +708,45 @@ func TestSlices(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------
+// TEST-SOURCE
+func TestSource(t *testing.T) {
+	opts := []Option{
+		WithFS(dataFs, "testdata/a.json"),
+		WithFS(dataFs, "testdata/b.json"),
+		WithEnv(EnvPrefix("CFG_TESTDATA_")),
+		WithMap(map[string]any{"run": map[string]any{"count": 20}, "list": []any{1, 2}}),
+		WithString(`{"run": {"speed": 1}}`),
+	}
+	s, err := NewSettings(opts...)
+	if err != nil {
+		t.Fatalf("TestSource has error %v", err)
+	}
+	table := []struct {
+		path   string
+		want   string
+		wantOk bool
+	}{
+		{"age", "testdata/a.json#/age", true},
+		{"a", "testdata/b.json#/a", true},
+		{"A", "$CFG_TESTDATA_A", true},
+		{"run/count", "map option 3", true},
+		{"run/speed", "option 4", true},
+		{"list/1", "map option 3", true},
+		{"run", "", false},
+		{"missing", "", false},
+	}
+	for i, v := range table {
+		have, haveOk := s.Source(v.path)
+		if haveOk != v.wantOk || (haveOk && have.String() != v.want) {
+			t.Fatalf("TestSource %v has %v (%v) but wants %v (%v)", i, have, haveOk, v.want, v.wantOk)
+		}
+	}
+	if want := "age = 32  (testdata/a.json#/age)\n"; !strings.Contains(s.Explain(), want) {
+		t.Fatalf("TestSource explain has %v but wants %v", s.Explain(), want)
+	}
+}
+
 // ---------------------------------------------------------
 // TEST-STRING
 func TestString(t *testing.T) {
@@ -205,6 +816,114 @@ func TestStrings(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------
+// TEST-TREE
+func TestTree(t *testing.T) {
+	table := []struct {
+		opts   []Option
+		subset []string
+		terms  []string
+	}{
+		{[]Option{WithFS(dataFs, "testdata/c.json")}, nil, []string{`{type}=object`, `list/{type}=array`, `list/0=a`, `list/{count}=3`, `map/{keys}="x,y"`}},
+		{[]Option{WithFS(dataFs, "testdata/c.json")}, []string{"list"}, []string{`{type}=array`, `*^=""`, `2=d`}},
+		{[]Option{WithMap(map[string]any{"a": map[string]any{"b": 1.5, "c": true}})}, []string{"a"}, []string{`b=1.5`, `b/{type}=number`, `c/{type}=bool`}},
+	}
+	for i, v := range table {
+		s, err := NewSettings(v.opts...)
+		if err != nil {
+			t.Fatalf("TestTree %v has error %v", i, err)
+		}
+		for _, path := range v.subset {
+			s = s.Subset(path)
+		}
+		if err := jacl.RunTree(s, v.terms...); err != nil {
+			t.Fatalf("TestTree %v %v", i, err)
+		}
+		// The tree is a copy.
+		if m, ok := s.Tree().(map[string]any); ok {
+			m["added"] = true
+			if _, ok := s.lookup("added"); ok {
+				t.Fatalf("TestTree %v tree isn't a copy", i)
+			}
+		}
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-WITH
+func TestWith(t *testing.T) {
+	src := `{"a": 1, "m": {"x": 1, "n": {"y": 1}}, "l": [1, {"z": 1}], "other": {"o": 1}}`
+	table := []struct {
+		path    string
+		value   any
+		without bool
+		cmp     []string
+	}{
+		{"a", 2, false, []string{`a=2`, `m/x=1`}},
+		{"m/n/y", "v", false, []string{`m/n/y=v`, `m/x=1`}},
+		{"new/deep/k", true, false, []string{`new/deep/k=true`}},
+		{"a/b", 3, false, []string{`a/b=3`}},
+		{"l/1/z", 5, false, []string{`l/1/z=5`, `l/0=1`}},
+		{"l/2", 7, false, []string{`l/{count}=3`, `l/2=7`}},
+		{"l/9", 7, false, []string{`l/{count}=2`}},
+		{"a", nil, true, []string{`m/x=1`}},
+		{"m/n", nil, true, []string{`m/x=1`, `m/{count}=1`}},
+		{"l/0", nil, true, []string{`l/{count}=1`, `l/0/z=1`}},
+		{"missing/x", nil, true, []string{`a=1`}},
+	}
+	for i, v := range table {
+		s, err := NewSettings(WithString(src))
+		if err != nil {
+			t.Fatalf("TestWith %v has error %v", i, err)
+		}
+		before, _ := s.asJson()
+		var have Settings
+		if v.without {
+			have = s.Without(v.path)
+			// Slice elements shift down, so only maps are checked.
+			if _, ok := have.lookup(v.path); ok && !strings.HasPrefix(v.path, "l/") {
+				t.Fatalf("TestWith %v still has %v", i, v.path)
+			}
+		} else {
+			have = s.With(v.path, v.value)
+		}
+		if err := jacl.Run(have.t, v.cmp...); err != nil {
+			t.Fatalf("TestWith %v %v", i, err)
+		} else if after, _ := s.asJson(); string(before) != string(after) {
+			t.Fatalf("TestWith %v modified the original to %s", i, after)
+		}
+		// Untouched branches are shared.
+		if reflect.ValueOf(have.t["other"]).UnsafePointer() != reflect.ValueOf(s.t["other"]).UnsafePointer() {
+			t.Fatalf("TestWith %v copied an untouched branch", i)
+		}
+	}
+
+	// A slice subset edits the slice, not the map that holds it.
+	s, err := NewSettings(WithString(`{"list": [{"n": "a"}, {"n": "b"}]}`))
+	if err != nil {
+		t.Fatalf("TestWith has error %v", err)
+	}
+	list := s.Subset("list")
+	slices := []struct {
+		have Settings
+		want any
+	}{
+		{list.With("0/n", "z"), []any{map[string]any{"n": "z"}, map[string]any{"n": "b"}}},
+		{list.With("2", "c"), []any{map[string]any{"n": "a"}, map[string]any{"n": "b"}, "c"}},
+		{list.Without("0"), []any{map[string]any{"n": "b"}}},
+		{list.Merge(list, MergeAppend), []any{map[string]any{"n": "a"}, map[string]any{"n": "b"}, map[string]any{"n": "a"}, map[string]any{"n": "b"}}},
+		{list.With("", map[string]any{"k": 1}), map[string]any{"k": 1}},
+	}
+	for i, v := range slices {
+		if have := v.have.Tree(); !reflect.DeepEqual(have, v.want) {
+			t.Fatalf("TestWith slice %v has %v but wants %v", i, have, v.want)
+		}
+	}
+	if have := list.Tree(); len(have.([]any)) != 2 {
+		t.Fatalf("TestWith slice modified the original to %v", have)
+	}
+}
+
 // ---------------------------------------------------------
 // TEST-WITH-SETTINGS
 func TestWithSettings(t *testing.T) {
@@ -235,6 +954,96 @@ func TestWithSettings(t *testing.T) {
 	f("a.json", "b.json", "c.json")
 }
 
+// ---------------------------------------------------------
+// TEST-WATCHER
+func TestWatcher(t *testing.T) {
+	type step struct {
+		files       map[string]string
+		wantChanges []Change
+		wantTopics  []string
+		cmp         []string
+	}
+	table := []struct {
+		files map[string]string
+		steps []step
+	}{
+		{map[string]string{"a.json": `{"a": 1, "run": {"count": 10}}`}, []step{
+			// No file changes
+			{nil, nil, nil, []string{`a=1`}},
+			// Modified file
+			{map[string]string{"a.json": `{"a": 2, "run": {"count": 10}}`}, []Change{{"a", 1.0, 2.0}}, nil, []string{`a=2`}},
+			// Added file, with changes under the published topic
+			{map[string]string{"b.json": `{"run": {"count": 11, "fast": true}}`}, []Change{{"run/count", 10.0, 11.0}, {"run/fast", nil, true}}, []string{"cfg/run/count", "cfg/run/fast"}, []string{`run/count=11`}},
+			// Modified file without a value change
+			{map[string]string{"a.json": `{"a": 2, "run": {"count": 10} }`}, nil, nil, []string{`a=2`}},
+			// Removed file
+			{map[string]string{"b.json": ""}, []Change{{"run/count", 11.0, 10.0}, {"run/fast", true, nil}}, []string{"cfg/run/count", "cfg/run/fast"}, []string{`run/count=10`}},
+		}},
+		// Private values are redacted from changes and topics
+		{map[string]string{"a.json": `{"run": {"_token": "s1"}}`}, []step{
+			{map[string]string{"a.json": `{"run": {"_token": "s2"}}`}, []Change{{"run/_token", RedactedValue, RedactedValue}}, []string{"cfg/run/_token"}, []string{`run/_token=s2`}},
+			{map[string]string{"b.json": `{"run": {"_key": "k"}}`}, []Change{{"run/_key", nil, RedactedValue}}, []string{"cfg/run/_key"}, []string{`run/_key=k`}},
+		}},
+	}
+	for i, v := range table {
+		fsys := fstest.MapFS{}
+		setWatcherFiles(fsys, v.files, 0)
+		w, err := NewWatcher(WithFS(fsys, "*.json"))
+		if err != nil {
+			t.Fatalf("TestWatcher %v has error %v", i, err)
+		}
+		var haveChanges []Change
+		w.Subscribe(func(s Settings, changes []Change) {
+			haveChanges = append(haveChanges, changes...)
+		})
+		var haveTopics []string
+		r := msg.NewRouter()
+		w.Publish(r, "cfg")
+		msg.Sub(r, "cfg/run/#", func(topic string, c Change) {
+			haveTopics = append(haveTopics, topic)
+			if !reflect.DeepEqual(c, changeAt(haveChanges, c.Path)) {
+				t.Fatalf("TestWatcher %v published %v but notified %v", i, c, changeAt(haveChanges, c.Path))
+			}
+		})
+		for j, s := range v.steps {
+			haveChanges, haveTopics = nil, nil
+			setWatcherFiles(fsys, s.files, j+1)
+			changes, err := w.Poll()
+			if err != nil {
+				t.Fatalf("TestWatcher %v step %v has error %v", i, j, err)
+			} else if !reflect.DeepEqual(changes, s.wantChanges) || !reflect.DeepEqual(haveChanges, s.wantChanges) {
+				t.Fatalf("TestWatcher %v step %v has changes %v (notified %v) but wants %v", i, j, changes, haveChanges, s.wantChanges)
+			} else if !reflect.DeepEqual(haveTopics, s.wantTopics) {
+				t.Fatalf("TestWatcher %v step %v has topics %v but wants %v", i, j, haveTopics, s.wantTopics)
+			}
+			if err := jacl.Run(w.Settings().t, s.cmp...); err != nil {
+				t.Fatalf("TestWatcher %v step %v has error %v", i, j, err)
+			}
+		}
+	}
+}
+
+// changeAt answers the change to the path.
+func changeAt(changes []Change, path string) Change {
+	for _, c := range changes {
+		if c.Path == path {
+			return c
+		}
+	}
+	return Change{}
+}
+
+// setWatcherFiles updates the files, removing any with empty content.
+func setWatcherFiles(fsys fstest.MapFS, files map[string]string, step int) {
+	for name, content := range files {
+		if content == "" {
+			delete(fsys, name)
+		} else {
+			fsys[name] = &fstest.MapFile{Data: []byte(content), ModTime: time.Unix(int64(step), 0)}
+		}
+	}
+}
+
 // ---------------------------------------------------------
 // TEST-HEX-TO-UINT8
 func TestHexToUint8(t *testing.T) {
@@ -0,0 +1,124 @@
+package cfg
+
+import (
+	"bytes"
+	"encoding/json"
+	"path"
+	"strings"
+
+	"github.com/hackborn/onefunc/sync"
+)
+
+// Decoder converts the contents of a file into a settings tree.
+// Decoders must produce the same types as encoding/json so all
+// formats merge together: map[string]any, []any, string, float64,
+// bool and nil.
+type Decoder func(dat []byte) (map[string]any, error)
+
+// RegisterDecoder sets the decoder used by WithFS for files with
+// the extension (".yaml"). It replaces any existing decoder.
+func RegisterDecoder(ext string, d Decoder) {
+	defer sync.Lock(&decodersMu).Unlock()
+	decoders[strings.ToLower(ext)] = d
+}
+
+// decoderFor answers the decoder for the file name, based on
+// its extension. Unknown extensions are decoded as JSON.
+func decoderFor(name string) Decoder {
+	defer sync.Lock(&decodersMu).Unlock()
+	if d, ok := decoders[strings.ToLower(path.Ext(name))]; ok {
+		return d
+	}
+	return DecodeJson
+}
+
+// DecodeJson decodes standard JSON.
+func DecodeJson(dat []byte) (map[string]any, error) {
+	m := make(map[string]any)
+	err := json.Unmarshal(dat, &m)
+	return m, err
+}
+
+// DecodeJsonc decodes JSON that can contain comments (both
+// "//" and "/* */") and trailing commas. Comments and trailing
+// commas are blanked out before decoding, so error offsets
+// still match the original data.
+func DecodeJsonc(dat []byte) (map[string]any, error) {
+	return DecodeJson(stripJsonTrailingCommas(stripJsonComments(dat)))
+}
+
+// stripJsonComments answers a copy of the data with every
+// comment replaced by spaces. Newlines are kept.
+func stripJsonComments(dat []byte) []byte {
+	out := bytes.Clone(dat)
+	inString, escaped := false, false
+	for i := 0; i < len(out); i++ {
+		ch := out[i]
+		switch {
+		case inString:
+			if escaped {
+				escaped = false
+			} else if ch == '\\' {
+				escaped = true
+			} else if ch == '"' {
+				inString = false
+			}
+		case ch == '"':
+			inString = true
+		case ch == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case ch == '/' && i+1 < len(out) && out[i+1] == '*':
+			// Unterminated comments are blanked to the end.
+			end := len(out)
+			if j := bytes.Index(out[i+2:], []byte("*/")); j >= 0 {
+				end = i + 2 + j + 2
+			}
+			for ; i < end; i++ {
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+			i--
+		}
+	}
+	return out
+}
+
+// stripJsonTrailingCommas blanks out any comma that
+// is followed only by whitespace and a closing bracket.
+func stripJsonTrailingCommas(dat []byte) []byte {
+	inString, escaped := false, false
+	for i, ch := range dat {
+		switch {
+		case inString:
+			if escaped {
+				escaped = false
+			} else if ch == '\\' {
+				escaped = true
+			} else if ch == '"' {
+				inString = false
+			}
+		case ch == '"':
+			inString = true
+		case ch == ',':
+			rest := bytes.TrimLeft(dat[i+1:], " \t\r\n")
+			if len(rest) > 0 && (rest[0] == '}' || rest[0] == ']') {
+				dat[i] = ' '
+			}
+		}
+	}
+	return dat
+}
+
+var (
+	decodersMu sync.Mutex
+	decoders   = map[string]Decoder{
+		".env":   DecodeEnv,
+		".ini":   DecodeIni,
+		".json":  DecodeJson,
+		".jsonc": DecodeJsonc,
+		".toml":  DecodeIni,
+	}
+)
@@ -0,0 +1,49 @@
+package cfg
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// DecodeEnv decodes a .env file into a flat map of strings:
+//
+//	# Comments start with "#"
+//	export NAME=value
+//	PLAIN=value # trailing comment
+//	QUOTED="line one\nline two"
+//	LITERAL='no $escapes\n'
+//
+// Double quoted values support Go escapes, single quoted values
+// are literal. Variables are not expanded.
+func DecodeEnv(dat []byte) (map[string]any, error) {
+	m := make(map[string]any)
+	scanner := bufio.NewScanner(bytes.NewReader(dat))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		text = strings.TrimSpace(strings.TrimPrefix(text, "export "))
+		name, value, ok := strings.Cut(text, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("line %v: bad assignment %v", line, text)
+		}
+		value = strings.TrimSpace(value)
+		if value != "" && (value[0] == '"' || value[0] == '\'') {
+			v, rest, err := parseIniString(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %v: %w", line, err)
+			} else if rest = strings.TrimSpace(rest); rest != "" && rest[0] != '#' {
+				return nil, fmt.Errorf("line %v: unexpected \"%v\"", line, rest)
+			}
+			value = v
+		} else if i := strings.Index(value, " #"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+		m[name] = value
+	}
+	return m, scanner.Err()
+}
@@ -0,0 +1,308 @@
+package cfg
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DecodeIni decodes INI files and a subset of TOML:
+//
+//	# Comments start with "#" or ";"
+//	name = "quoted string"
+//	path = bare INI string
+//	count = 10
+//	debug = true
+//	tags = ["a", "b", 3]
+//	point = { x = 1, y = 2 }
+//	run.speed = 2.5
+//
+//	[section.child]
+//	key = 'literal string'
+//
+//	[[servers]]
+//	host = "a"
+//
+// Section headers and dotted keys create nested maps, and "[[name]]"
+// appends a new map to the slice at name. Numbers are decoded as
+// float64, to match JSON. Unquoted values that aren't a number or
+// bool are strings. Arrays and inline tables must be on a single line,
+// and multi-line strings and dates aren't supported.
+func DecodeIni(dat []byte) (map[string]any, error) {
+	root := make(map[string]any)
+	section := root
+	scanner := bufio.NewScanner(bytes.NewReader(dat))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		var err error
+		switch {
+		case text == "" || text[0] == '#' || text[0] == ';':
+		case strings.HasPrefix(text, "[["):
+			section, err = iniArrayTable(root, text)
+		case text[0] == '[':
+			section, err = iniTable(root, text)
+		default:
+			err = iniKeyValue(section, text)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %w", line, err)
+		}
+	}
+	return root, scanner.Err()
+}
+
+func iniTable(root map[string]any, text string) (map[string]any, error) {
+	name, rest, ok := strings.Cut(text[1:], "]")
+	if !ok || !iniIsComment(rest) {
+		return nil, fmt.Errorf("bad section header %v", text)
+	}
+	keys, err := iniKeys(name)
+	if err != nil {
+		return nil, err
+	}
+	return iniMap(root, keys)
+}
+
+func iniArrayTable(root map[string]any, text string) (map[string]any, error) {
+	name, rest, ok := strings.Cut(text[2:], "]]")
+	if !ok || !iniIsComment(rest) {
+		return nil, fmt.Errorf("bad array header %v", text)
+	}
+	keys, err := iniKeys(name)
+	if err != nil {
+		return nil, err
+	}
+	parent, err := iniMap(root, keys[:len(keys)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := keys[len(keys)-1]
+	list, ok := parent[last].([]any)
+	if _, exists := parent[last]; exists && !ok {
+		return nil, fmt.Errorf("key \"%v\" is not an array", last)
+	}
+	m := make(map[string]any)
+	parent[last] = append(list, m)
+	return m, nil
+}
+
+func iniKeyValue(section map[string]any, text string) error {
+	name, value, ok := strings.Cut(text, "=")
+	if !ok {
+		return fmt.Errorf("missing \"=\" in %v", text)
+	}
+	keys, err := iniKeys(name)
+	if err != nil {
+		return err
+	}
+	parent, err := iniMap(section, keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+	v, rest, err := parseIniValue(strings.TrimSpace(value), true)
+	if err != nil {
+		return err
+	} else if !iniIsComment(rest) {
+		return fmt.Errorf("unexpected \"%v\"", strings.TrimSpace(rest))
+	}
+	last := keys[len(keys)-1]
+	if _, exists := parent[last]; exists {
+		return fmt.Errorf("duplicate key \"%v\"", strings.TrimSpace(name))
+	}
+	parent[last] = v
+	return nil
+}
+
+// iniMap answers the map at keys, creating maps as needed.
+// A key that addresses a slice uses the last element.
+func iniMap(m map[string]any, keys []string) (map[string]any, error) {
+	for _, k := range keys {
+		switch t := m[k].(type) {
+		case nil:
+			child := make(map[string]any)
+			m[k] = child
+			m = child
+		case map[string]any:
+			m = t
+		case []any:
+			if len(t) == 0 {
+				return nil, fmt.Errorf("key \"%v\" is not a table", k)
+			}
+			last, ok := t[len(t)-1].(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("key \"%v\" is not a table", k)
+			}
+			m = last
+		default:
+			return nil, fmt.Errorf("key \"%v\" is not a table", k)
+		}
+	}
+	return m, nil
+}
+
+// iniKeys splits a dotted key, which can contain quoted parts.
+func iniKeys(name string) ([]string, error) {
+	var keys []string
+	for rest := strings.TrimSpace(name); ; {
+		var key string
+		if rest != "" && (rest[0] == '"' || rest[0] == '\'') {
+			v, r, err := parseIniString(rest)
+			if err != nil {
+				return nil, err
+			}
+			key, rest = v, strings.TrimSpace(r)
+		} else {
+			end := strings.IndexByte(rest, '.')
+			if end < 0 {
+				end = len(rest)
+			}
+			key, rest = strings.TrimSpace(rest[:end]), rest[end:]
+		}
+		if key == "" {
+			return nil, fmt.Errorf("empty key in \"%v\"", name)
+		}
+		keys = append(keys, key)
+		if rest == "" {
+			return keys, nil
+		} else if rest[0] != '.' {
+			return nil, fmt.Errorf("bad key \"%v\"", name)
+		}
+		rest = strings.TrimSpace(rest[1:])
+	}
+}
+
+// parseIniValue answers the value at the start of s, and the
+// rest of s. A top-level bare string runs to the end of the line
+// or a comment, otherwise it stops at the end of the element.
+func parseIniValue(s string, topLevel bool) (any, string, error) {
+	if s == "" {
+		return nil, "", fmt.Errorf("missing value")
+	}
+	switch s[0] {
+	case '"', '\'':
+		return parseIniString(s)
+	case '[':
+		return parseIniArray(s)
+	case '{':
+		return parseIniInline(s)
+	}
+	end := len(s)
+	for i := range s {
+		if topLevel && (s[i] == '#' || s[i] == ';') && i > 0 && (s[i-1] == ' ' || s[i-1] == '\t') {
+			end = i
+			break
+		} else if !topLevel && strings.IndexByte(",]}", s[i]) >= 0 {
+			end = i
+			break
+		}
+	}
+	token, rest := strings.TrimSpace(s[:end]), s[end:]
+	switch token {
+	case "true":
+		return true, rest, nil
+	case "false":
+		return false, rest, nil
+	}
+	if f, err := strconv.ParseFloat(strings.ReplaceAll(token, "_", ""), 64); err == nil {
+		return f, rest, nil
+	} else if !topLevel && token == "" {
+		return nil, rest, fmt.Errorf("missing value")
+	}
+	return token, rest, nil
+}
+
+// parseIniString parses a double quoted string with escapes,
+// or a single quoted literal string.
+func parseIniString(s string) (string, string, error) {
+	if s[0] == '\'' {
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string %v", s)
+		}
+		return s[1 : end+1], s[end+2:], nil
+	}
+	for i := 1; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+		} else if s[i] == '"' {
+			v, err := strconv.Unquote(s[:i+1])
+			return v, s[i+1:], err
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string %v", s)
+}
+
+func parseIniArray(s string) ([]any, string, error) {
+	list := []any{}
+	rest := strings.TrimSpace(s[1:])
+	for {
+		if rest == "" {
+			return nil, "", fmt.Errorf("unterminated array %v", s)
+		} else if rest[0] == ']' {
+			return list, rest[1:], nil
+		}
+		v, r, err := parseIniValue(rest, false)
+		if err != nil {
+			return nil, "", err
+		}
+		list = append(list, v)
+		rest, err = iniNextElement(r, ']')
+		if err != nil {
+			return nil, "", err
+		}
+	}
+}
+
+func parseIniInline(s string) (map[string]any, string, error) {
+	m := make(map[string]any)
+	rest := strings.TrimSpace(s[1:])
+	for {
+		if rest == "" {
+			return nil, "", fmt.Errorf("unterminated table %v", s)
+		} else if rest[0] == '}' {
+			return m, rest[1:], nil
+		}
+		name, r, ok := strings.Cut(rest, "=")
+		if !ok {
+			return nil, "", fmt.Errorf("missing \"=\" in %v", s)
+		}
+		keys, err := iniKeys(name)
+		if err != nil {
+			return nil, "", err
+		}
+		parent, err := iniMap(m, keys[:len(keys)-1])
+		if err != nil {
+			return nil, "", err
+		}
+		v, r, err := parseIniValue(strings.TrimSpace(r), false)
+		if err != nil {
+			return nil, "", err
+		}
+		parent[keys[len(keys)-1]] = v
+		rest, err = iniNextElement(r, '}')
+		if err != nil {
+			return nil, "", err
+		}
+	}
+}
+
+// iniNextElement skips the separator after an array or
+// table element, answering the rest starting at the next
+// element or the closing bracket.
+func iniNextElement(s string, closing byte) (string, error) {
+	s = strings.TrimSpace(s)
+	if s != "" && s[0] == ',' {
+		return strings.TrimSpace(s[1:]), nil
+	} else if s != "" && s[0] == closing {
+		return s, nil
+	}
+	return "", fmt.Errorf("expected \",\" or \"%c\" at %v", closing, s)
+}
+
+// iniIsComment answers true if s is empty or only a comment.
+func iniIsComment(s string) bool {
+	s = strings.TrimSpace(s)
+	return s == "" || s[0] == '#' || s[0] == ';'
+}
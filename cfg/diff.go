@@ -0,0 +1,66 @@
+package cfg
+
+import (
+	"reflect"
+	"slices"
+)
+
+// SettingsDiff lists the leaf paths that differ between
+// two Settings. Each list is sorted.
+type SettingsDiff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// Empty answers true if the diff has no differences.
+func (d SettingsDiff) Empty() bool {
+	return len(d.Added) < 1 && len(d.Removed) < 1 && len(d.Changed) < 1
+}
+
+// Diff answers the leaf paths that were added, removed or
+// changed going from a to b. Slice elements are compared
+// individually ("tags/0").
+func Diff(a, b Settings) SettingsDiff {
+	d := SettingsDiff{}
+	visitDiff(a, b, func(path string, oldv any, oldok bool, newv any, newok bool) {
+		switch {
+		case !oldok:
+			d.Added = append(d.Added, path)
+		case !newok:
+			d.Removed = append(d.Removed, path)
+		default:
+			d.Changed = append(d.Changed, path)
+		}
+	})
+	return d
+}
+
+// diffLeaves answers every leaf path that differs between
+// the settings, in sorted order.
+func diffLeaves(a, b Settings) []Change {
+	var changes []Change
+	visitDiff(a, b, func(path string, oldv any, oldok bool, newv any, newok bool) {
+		changes = append(changes, Change{Path: path, Old: oldv, New: newv})
+	})
+	return changes
+}
+
+type diffFunc func(path string, oldv any, oldok bool, newv any, newok bool)
+
+// visitDiff calls fn with every leaf path that differs
+// between the settings, in sorted order.
+func visitDiff(a, b Settings, fn diffFunc) {
+	av, _ := a.lookup("")
+	bv, _ := b.lookup("")
+	paths := append(leafPaths(av, ""), leafPaths(bv, "")...)
+	slices.Sort(paths)
+	for _, path := range slices.Compact(paths) {
+		oldv, oldok := a.lookup(path)
+		newv, newok := b.lookup(path)
+		if oldok && newok && reflect.DeepEqual(oldv, newv) {
+			continue
+		}
+		fn(path, oldv, oldok, newv, newok)
+	}
+}
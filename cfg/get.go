@@ -0,0 +1,312 @@
+package cfg
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/hackborn/onefunc/math/geo"
+	"github.com/hackborn/onefunc/sync"
+)
+
+// Get answers the value at path converted to T. Supported types:
+//   - bool, ints, uints, floats and string, with the same rules as the
+//     getters (Bool, Int64, etc.).
+//   - time.Duration, from a string ("1.5s", see time.ParseDuration)
+//     or a number of seconds, int or float.
+//   - ByteSize, from a string ("64MiB", "1.5GB") or a number of bytes.
+//   - time.Time, from an RFC 3339 string, a date ("2006-01-02") or a
+//     number of Unix seconds, int or float.
+//   - []int64, []float64 and []string, from a slice.
+//   - map[string]string, from a map of scalars.
+//   - geo.PtF, from {"x": 1, "y": 2} or [1, 2].
+//   - geo.RngF, from {"min": 1, "max": 2} or [1, 2].
+//   - Any type with a converter, see RegisterConverter().
+func Get[T any](s Settings, path string) (T, bool) {
+	return getType(s, path, leafGet[T])
+}
+
+// MustGet answers the value at path converted to T,
+// or fallback if path is absent or can't be converted.
+func MustGet[T any](s Settings, path string, fallback T) T {
+	if v, ok := Get[T](s, path); ok {
+		return v
+	}
+	return fallback
+}
+
+// ConvertFunc converts a raw settings value (map[string]any,
+// []any, string, float64, bool or nil) to T.
+type ConvertFunc[T any] func(v any) (T, bool)
+
+// RegisterConverter sets the converter used by Get and Bind for
+// type T, replacing any existing converter, including the built-in ones.
+func RegisterConverter[T any](fn ConvertFunc[T]) {
+	defer sync.Lock(&convertersMu).Unlock()
+	converters[reflect.TypeFor[T]()] = wrapConverter(fn)
+}
+
+// ByteSize is a number of bytes.
+type ByteSize int64
+
+// String answers the size in the largest binary unit
+// that divides it evenly ("64MiB").
+func (b ByteSize) String() string {
+	for _, u := range []struct {
+		name string
+		size ByteSize
+	}{{"TiB", 1 << 40}, {"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10}} {
+		if b >= u.size && b%u.size == 0 {
+			return fmt.Sprintf("%v%v", int64(b/u.size), u.name)
+		}
+	}
+	return fmt.Sprintf("%vB", int64(b))
+}
+
+// leafGet takes a path with no separator, i.e.
+// assumes it is an index in this map and not a subset,
+// and returns the value.
+func leafGet[T any](s Settings, p string) (T, bool) {
+	var t T
+	raw, ok := leafRaw(s, p)
+	if !ok {
+		return t, false
+	}
+	v, ok := convert(raw, reflect.TypeFor[T]())
+	if !ok {
+		return t, false
+	}
+	return v.Interface().(T), true
+}
+
+// leafRaw answers the raw value of the key, or the
+// slice element for a slice settings.
+func leafRaw(s Settings, p string) (any, bool) {
+	if s.sliceKey != "" {
+		list, _ := s.t[s.sliceKey].([]any)
+		i, err := strconv.Atoi(p)
+		if err != nil || i < 0 || i >= len(list) {
+			return nil, false
+		}
+		return list[i], true
+	}
+	v, ok := s.t[p]
+	return v, ok
+}
+
+// convert answers the raw value converted to the type,
+// using a registered converter or the scalar getters.
+func convert(raw any, t reflect.Type) (reflect.Value, bool) {
+	if fn, ok := converterFor(t); ok {
+		if v, ok := fn(raw); ok {
+			return reflect.ValueOf(v), true
+		}
+		return reflect.Value{}, false
+	}
+	v := reflect.New(t).Elem()
+	if rv := reflect.ValueOf(raw); raw != nil && rv.Type().AssignableTo(t) {
+		// Maps and slices are copied so the client
+		// can't modify the settings through them.
+		v.Set(reflect.ValueOf(cloneValue(raw)))
+		return v, true
+	}
+	return v, setLeaf(raw, v)
+}
+
+func converterFor(t reflect.Type) (func(any) (any, bool), bool) {
+	defer sync.Lock(&convertersMu).Unlock()
+	fn, ok := converters[t]
+	return fn, ok
+}
+
+func convertDuration(v any) (time.Duration, bool) {
+	switch t := v.(type) {
+	case time.Duration:
+		return t, true
+	case string:
+		d, err := time.ParseDuration(t)
+		return d, err == nil
+	case float64:
+		return time.Duration(t * float64(time.Second)), true
+	}
+	if n, ok := intValue(v); ok {
+		return time.Duration(n) * time.Second, true
+	}
+	return 0, false
+}
+
+func convertByteSize(v any) (ByteSize, bool) {
+	switch t := v.(type) {
+	case string:
+		t = strings.TrimSpace(t)
+		i := strings.IndexFunc(t, func(r rune) bool {
+			return unicode.IsLetter(r)
+		})
+		if i < 0 {
+			i = len(t)
+		}
+		n, err := strconv.ParseFloat(strings.TrimSpace(t[:i]), 64)
+		unit, ok := byteUnits[strings.ToLower(t[i:])]
+		if err != nil || !ok {
+			return 0, false
+		}
+		return ByteSize(n * float64(unit)), true
+	case float64:
+		return ByteSize(t), true
+	}
+	if n, ok := intValue(v); ok {
+		return ByteSize(n), true
+	}
+	return 0, false
+}
+
+func convertTime(v any) (time.Time, bool) {
+	switch t := v.(type) {
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, time.DateTime, time.DateOnly} {
+			if tm, err := time.Parse(layout, t); err == nil {
+				return tm, true
+			}
+		}
+	case float64:
+		return time.Unix(0, int64(t*float64(time.Second))).UTC(), true
+	case time.Time:
+		return t, true
+	}
+	if n, ok := intValue(v); ok {
+		return time.Unix(n, 0).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// intValue answers v if it is any int or uint kind, such
+// as values added through WithMap or a custom decoder.
+func intValue(v any) (int64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if u := rv.Uint(); u <= math.MaxInt64 {
+			return int64(u), true
+		}
+	}
+	return 0, false
+}
+
+// convertSlice answers a converter for slices of type T.
+func convertSlice[T any](v any) ([]T, bool) {
+	list, ok := v.([]any)
+	if !ok {
+		return nil, false
+	}
+	dst := make([]T, 0, len(list))
+	for _, item := range list {
+		cv, ok := convert(item, reflect.TypeFor[T]())
+		if !ok {
+			return nil, false
+		}
+		dst = append(dst, cv.Interface().(T))
+	}
+	return dst, true
+}
+
+func convertStringMap(v any) (map[string]string, bool) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	dst := make(map[string]string, len(m))
+	for k, item := range m {
+		str, err := formatScalar(item)
+		if err != nil {
+			return nil, false
+		}
+		dst[k] = str
+	}
+	return dst, true
+}
+
+func convertPtF(v any) (geo.PtF, bool) {
+	a, b, ok := convertPair(v, "x", "y")
+	return geo.PtF{X: a, Y: b}, ok
+}
+
+func convertRngF(v any) (geo.RngF, bool) {
+	a, b, ok := convertPair(v, "min", "max")
+	return geo.RngF{Min: a, Max: b}, ok
+}
+
+// convertPair answers the two numbers in a two element
+// slice, or the keys of a map. Missing keys are 0.
+func convertPair(v any, keyA, keyB string) (float64, float64, bool) {
+	switch t := v.(type) {
+	case []any:
+		if len(t) != 2 {
+			return 0, 0, false
+		}
+		a, oka := t[0].(float64)
+		b, okb := t[1].(float64)
+		return a, b, oka && okb
+	case map[string]any:
+		var a, b float64
+		for k, item := range t {
+			f, ok := item.(float64)
+			if !ok {
+				return 0, 0, false
+			}
+			switch strings.ToLower(k) {
+			case keyA:
+				a = f
+			case keyB:
+				b = f
+			default:
+				return 0, 0, false
+			}
+		}
+		return a, b, true
+	}
+	return 0, 0, false
+}
+
+func wrapConverter[T any](fn ConvertFunc[T]) func(any) (any, bool) {
+	return func(v any) (any, bool) {
+		return fn(v)
+	}
+}
+
+var (
+	convertersMu sync.Mutex
+	// converters is populated in init(), since the slice
+	// converters refer back to it.
+	converters = make(map[reflect.Type]func(any) (any, bool))
+
+	byteUnits = map[string]int64{
+		"":    1,
+		"b":   1,
+		"kb":  1000,
+		"mb":  1000 * 1000,
+		"gb":  1000 * 1000 * 1000,
+		"tb":  1000 * 1000 * 1000 * 1000,
+		"kib": 1 << 10,
+		"mib": 1 << 20,
+		"gib": 1 << 30,
+		"tib": 1 << 40,
+	}
+)
+
+func init() {
+	RegisterConverter(convertDuration)
+	RegisterConverter(convertByteSize)
+	RegisterConverter(convertTime)
+	RegisterConverter(convertSlice[int64])
+	RegisterConverter(convertSlice[float64])
+	RegisterConverter(convertSlice[string])
+	RegisterConverter(convertStringMap)
+	RegisterConverter(convertPtF)
+	RegisterConverter(convertRngF)
+}
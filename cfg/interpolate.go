@@ -0,0 +1,183 @@
+package cfg
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	oferrors "github.com/hackborn/onefunc/errors"
+	"github.com/hackborn/onefunc/io"
+)
+
+// WithInterpolation resolves variables in every string value
+// of the settings built so far, so it should be added after the
+// options it applies to. Supported variables:
+//
+//	${path/to/other} -- the value of another setting.
+//	${env:NAME} -- the value of an env var.
+//	$apppath$ -- sys variables, see io.ExpandPath().
+//
+// A value that is exactly one reference ("${run/count}") takes
+// the referenced value, including its type. Otherwise references
+// are formatted into the string, and must be scalars. References
+// are resolved recursively; cycles, missing settings and unset env
+// vars are errors. "$${" is an escaped "${".
+func WithInterpolation() Option {
+	return func(b Builder, eb oferrors.Block) {
+		in := &interpolator{s: Settings{t: b.Settings()}, state: make(map[string]resolveState)}
+		for _, path := range leafPaths(in.s.t, "") {
+			_, err := in.resolve(path, nil)
+			eb.AddError(err)
+		}
+	}
+}
+
+type interpolator struct {
+	s     Settings
+	state map[string]resolveState
+}
+
+type resolveState int
+
+const (
+	unresolved resolveState = iota
+	resolving
+	resolved
+)
+
+// resolve answers the value at path with all variables
+// resolved, replacing the value in the tree. stack is the
+// chain of references that led to the path.
+func (in *interpolator) resolve(path string, stack []string) (any, error) {
+	v, ok := in.s.lookup(path)
+	if !ok {
+		return nil, fmt.Errorf("%v: no setting for reference \"%v\"", stack[len(stack)-1], path)
+	}
+	switch in.state[path] {
+	case resolving:
+		return nil, fmt.Errorf("reference cycle %v -> %v", strings.Join(stack, " -> "), path)
+	case resolved:
+		return v, nil
+	}
+	switch t := v.(type) {
+	case map[string]any, []any:
+		// Resolve the children, so the container can be referenced.
+		for _, leaf := range leafPaths(t, path) {
+			if leaf == path {
+				// Empty container
+				continue
+			} else if _, err := in.resolve(leaf, stack); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case string:
+		in.state[path] = resolving
+		nv, err := in.expand(path, t, append(stack, path))
+		in.state[path] = resolved
+		if err != nil {
+			return nil, err
+		}
+		in.replace(path, nv)
+		return nv, nil
+	default:
+		return v, nil
+	}
+}
+
+// expand answers the string with all variables resolved.
+func (in *interpolator) expand(path, s string, stack []string) (any, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	sb := strings.Builder{}
+	for rest := s; rest != ""; {
+		start := strings.Index(rest, "${")
+		if start < 0 {
+			sb.WriteString(rest)
+			break
+		}
+		if start > 0 && rest[start-1] == '$' {
+			// Escaped
+			sb.WriteString(rest[:start-1] + "${")
+			rest = rest[start+2:]
+			continue
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("%v: unterminated variable in \"%v\"", path, s)
+		}
+		name := rest[start+2 : start+end]
+		v, err := in.variable(path, name, stack)
+		if err != nil {
+			return nil, err
+		}
+		// A single reference keeps the type of its value.
+		if start == 0 && end == len(rest)-1 && rest == s {
+			return cloneValue(v), nil
+		}
+		str, err := formatScalar(v)
+		if err != nil {
+			return nil, fmt.Errorf("%v: variable \"${%v}\" %w", path, name, err)
+		}
+		sb.WriteString(rest[:start] + str)
+		rest = rest[start+end+1:]
+	}
+	expanded, err := io.ExpandPath(sb.String())
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	return expanded, nil
+}
+
+// variable answers the value of the named variable.
+func (in *interpolator) variable(path, name string, stack []string) (any, error) {
+	if env, ok := strings.CutPrefix(name, envVarPrefix); ok {
+		v, ok := os.LookupEnv(env)
+		if !ok {
+			return nil, fmt.Errorf("%v: env var \"%v\" is not set", path, env)
+		}
+		return v, nil
+	}
+	ref := strings.Trim(name, pathSeparator)
+	if ref == "" {
+		return nil, fmt.Errorf("%v: empty variable", path)
+	}
+	return in.resolve(ref, stack)
+}
+
+// replace assigns the value at an existing path.
+func (in *interpolator) replace(path string, v any) {
+	parentPath, key := "", path
+	if i := strings.LastIndex(path, pathSeparator); i >= 0 {
+		parentPath, key = path[:i], path[i+1:]
+	}
+	parent, _ := in.s.lookup(parentPath)
+	switch t := parent.(type) {
+	case map[string]any:
+		t[key] = v
+	case []any:
+		if i, err := strconv.Atoi(key); err == nil && i >= 0 && i < len(t) {
+			t[i] = v
+		}
+	}
+}
+
+// formatScalar answers the value as a string, if it's a scalar.
+func formatScalar(v any) (string, error) {
+	switch t := v.(type) {
+	case string:
+		return t, nil
+	case map[string]any, []any:
+		return "", fmt.Errorf("is a %T, not a scalar", v)
+	case nil:
+		return "", nil
+	default:
+		return fmt.Sprintf("%v", t), nil
+	}
+}
+
+const (
+	envVarPrefix = "env:"
+)
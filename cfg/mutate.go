@@ -0,0 +1,190 @@
+package cfg
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// MergeStrategy determines how Merge() combines two Settings.
+type MergeStrategy int
+
+const (
+	MergeDeep    MergeStrategy = iota // Maps are merged recursively, other values from the other settings replace existing ones.
+	MergeReplace                      // Top level keys from the other settings replace existing ones.
+	MergeAppend                       // Like MergeDeep, but slices are appended.
+	MergeKeep                         // Like MergeDeep, but existing values are kept and only new keys are added.
+)
+
+// With answers a copy of the settings with value assigned at path.
+// Missing maps along the path are created, and any value along
+// the path that isn't a map or slice is replaced by a map. A slice
+// index can address an existing element, or one past the end to
+// append. If the path can't be assigned (a bad slice index), the
+// settings are answered unchanged.
+//
+// An empty path replaces the whole tree, which must be a map (or
+// a slice, for a slice subset).
+//
+// Only the maps and slices along the path are copied, the rest
+// of the tree is shared with the original.
+func (s Settings) With(path string, value any) Settings {
+	root, _ := s.lookup("")
+	v, ok := withValue(root, splitPath(path), value)
+	if !ok {
+		return s
+	}
+	if t, ok := s.withRoot(v, s.sliceKey); ok {
+		return t
+	}
+	return s
+}
+
+// Without answers a copy of the settings with the value at path
+// removed. Removing a slice element shifts the remaining elements.
+// Only the maps and slices along the path are copied.
+func (s Settings) Without(path string) Settings {
+	keys := splitPath(path)
+	if _, ok := s.lookup(path); !ok || len(keys) < 1 {
+		return s
+	}
+	root, _ := s.lookup("")
+	v, _ := withoutValue(root, keys)
+	t, _ := s.withRoot(v, s.sliceKey)
+	return t
+}
+
+// Merge answers a new settings that combines these settings
+// with other, using the strategy. Maps are only copied where
+// both settings have a map; all other values are shared.
+func (s Settings) Merge(other Settings, strategy MergeStrategy) Settings {
+	left, _ := s.lookup("")
+	right, _ := other.lookup("")
+	v := mergeValues(left, right, strategy, 0)
+	merged := Settings{private: slices.Concat(s.private, other.private)}
+	t, _ := merged.withRoot(v, cmp.Or(s.sliceKey, other.sliceKey))
+	return t
+}
+
+// withRoot answers a copy of my private paths with v as the root
+// of the tree. A slice root is stored under sliceKey, the same
+// way Subset() stores it. Other values can't be a root.
+func (s Settings) withRoot(v any, sliceKey string) (Settings, bool) {
+	switch t := v.(type) {
+	case map[string]any:
+		return Settings{t: t, private: s.private}, true
+	case []any:
+		if sliceKey != "" {
+			return Settings{t: map[string]any{sliceKey: t}, sliceKey: sliceKey, private: s.private}, true
+		}
+	}
+	return s, false
+}
+
+func withValue(v any, keys []string, value any) (any, bool) {
+	if len(keys) < 1 {
+		return value, true
+	}
+	key := keys[0]
+	switch t := v.(type) {
+	case []any:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i > len(t) {
+			return v, false
+		}
+		dst := slices.Clone(t)
+		if i == len(t) {
+			dst = append(dst, nil)
+		}
+		child, ok := withValue(dst[i], keys[1:], value)
+		dst[i] = child
+		return dst, ok
+	case map[string]any:
+		dst := make(map[string]any, len(t)+1)
+		for k, tv := range t {
+			dst[k] = tv
+		}
+		child, ok := withValue(t[key], keys[1:], value)
+		dst[key] = child
+		return dst, ok
+	default:
+		child, ok := withValue(nil, keys[1:], value)
+		return map[string]any{key: child}, ok
+	}
+}
+
+func withoutValue(v any, keys []string) (any, bool) {
+	key := keys[0]
+	switch t := v.(type) {
+	case []any:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(t) {
+			return v, false
+		}
+		if len(keys) == 1 {
+			return slices.Delete(slices.Clone(t), i, i+1), true
+		}
+		child, ok := withoutValue(t[i], keys[1:])
+		dst := slices.Clone(t)
+		dst[i] = child
+		return dst, ok
+	case map[string]any:
+		child, ok := t[key]
+		if !ok {
+			return v, false
+		}
+		dst := make(map[string]any, len(t))
+		for k, tv := range t {
+			dst[k] = tv
+		}
+		if len(keys) == 1 {
+			delete(dst, key)
+			return dst, true
+		}
+		dst[key], ok = withoutValue(child, keys[1:])
+		return dst, ok
+	default:
+		return v, false
+	}
+}
+
+// mergeValues answers the merge of right into left,
+// sharing every value it doesn't need to copy.
+func mergeValues(left, right any, strategy MergeStrategy, depth int) any {
+	lm, lok := left.(map[string]any)
+	rm, rok := right.(map[string]any)
+	if lok && rok && (strategy != MergeReplace || depth < 1) {
+		dst := make(map[string]any, len(lm)+len(rm))
+		for k, v := range lm {
+			dst[k] = v
+		}
+		for k, rv := range rm {
+			if lv, ok := lm[k]; ok {
+				dst[k] = mergeValues(lv, rv, strategy, depth+1)
+			} else {
+				dst[k] = rv
+			}
+		}
+		return dst
+	}
+	switch strategy {
+	case MergeKeep:
+		return left
+	case MergeAppend:
+		ls, lok := left.([]any)
+		rs, rok := right.([]any)
+		if lok && rok {
+			return slices.Concat(ls, rs)
+		}
+	}
+	return right
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, pathSeparator)
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, pathSeparator)
+}
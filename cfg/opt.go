@@ -19,10 +19,23 @@ type Option func(Builder, oferrors.Block)
 type Process func(map[string]any) map[string]any
 
 // WithFS loads all files that match the pattern
-// into the Settings. All matched files must be in JSON format.
+// into the Settings. Each file is decoded based on its
+// extension (see RegisterDecoder()): ".json" as strict JSON,
+// ".jsonc" as JSON with comments, ".ini" and ".toml" as INI,
+// ".env" as a .env file. Any other file must be in JSON format.
 // See path.Match() for match rules.
 func WithFS(fsys fs.FS, pattern string, processors ...Process) Option {
+	return WithFSDecoder(fsys, pattern, nil, processors...)
+}
+
+// WithFSDecoder loads all files that match the pattern into
+// the Settings, decoding each with the decoder. If decoder
+// is nil, it is selected by extension as in WithFS.
+func WithFSDecoder(fsys fs.FS, pattern string, decoder Decoder, processors ...Process) Option {
 	return func(b Builder, eb oferrors.Block) {
+		if sb, ok := b.(*_builder); ok {
+			sb.addSource(fsys, pattern)
+		}
 		matches, err := fs.Glob(fsys, pattern)
 		eb.AddError(err)
 		for _, match := range matches {
@@ -30,17 +43,25 @@ func WithFS(fsys fs.FS, pattern string, processors ...Process) Option {
 			if err != nil {
 				err = fmt.Errorf("%v: %w", match, err)
 				eb.AddError(err)
+				continue
 			}
 
-			s := b.NewSettings()
-			err = json.Unmarshal(dat, &s)
+			decode := decoder
+			if decode == nil {
+				decode = decoderFor(match)
+			}
+			s, err := decode(dat)
 			if err != nil {
 				err = fmt.Errorf("%v: %w", match, err)
 				eb.AddError(err)
+				continue
 			}
 			for _, process := range processors {
 				s = process(s)
 			}
+			setSource(b, func(keys []string) Source {
+				return Source{Kind: SourceFile, Name: match, Pointer: jsonPointer(keys)}
+			})
 			b.AddSettings(s)
 		}
 	}
@@ -51,18 +72,23 @@ func WithEnv(match EnvMatcher) Option {
 	return func(b Builder, eb oferrors.Block) {
 		envs := os.Environ()
 		s := b.NewSettings()
+		names := make(map[string]string)
 		for _, env := range envs {
 			pos := strings.Index(env, "=")
 			if pos > 0 && pos < len(env)-1 {
-				left := env[0:pos]
+				name := env[0:pos]
 				right := env[pos+1:]
-				left, err := match.Match(left)
+				left, err := match.Match(name)
 				eb.AddError(err)
 				if left != "" {
 					s[left] = right
+					names[left] = name
 				}
 			}
 		}
+		setSource(b, func(keys []string) Source {
+			return Source{Kind: SourceEnv, Name: names[keys[0]]}
+		})
 		b.AddSettings(s)
 	}
 }
@@ -135,15 +161,22 @@ func WithMap(m map[string]any) Option {
 		for k, v := range m {
 			s[k] = v
 		}
+		setSource(b, func(keys []string) Source {
+			return Source{Kind: SourceMap}
+		})
 		b.AddSettings(s)
 	}
 }
 
 // WithSettings acts as a deep copy on src.
+// Private paths are copied along with the values.
 func WithSettings(srcs ...Settings) Option {
 	return func(b Builder, eb oferrors.Block) {
 		t := b.NewSettings()
 		for _, src := range srcs {
+			if sb, ok := b.(*_builder); ok {
+				sb.private = append(sb.private, src.private...)
+			}
 			dat, err := src.asJson()
 			err = cmp.Or(err, json.Unmarshal(dat, &t))
 			if err != nil {
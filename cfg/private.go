@@ -0,0 +1,262 @@
+package cfg
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	oferrors "github.com/hackborn/onefunc/errors"
+)
+
+// WithPrivate marks the paths private. A "*" component
+// matches any map key or slice index, i.e. "users/*/token".
+//
+// Private settings hold secrets. They are dropped by
+// SaveSettings() and WriteJson(), and redacted by Print(),
+// Explain() and the Changes sent by a Watcher. A setting
+// is private if:
+//   - Its key, or the key of any parent, starts with PrivatePrefix
+//     ("_token", "_auth/user").
+//   - Its path, or the path of any parent, was supplied to
+//     WithPrivate(), for example from Schema.PrivatePaths().
+//   - It was an encrypted value.
+func WithPrivate(paths ...string) Option {
+	return func(b Builder, eb oferrors.Block) {
+		if sb, ok := b.(*_builder); ok {
+			for _, path := range paths {
+				sb.private = append(sb.private, strings.Trim(path, pathSeparator))
+			}
+		}
+	}
+}
+
+// WithKeyFile decrypts any encrypted values once all options
+// have run, using the AES key in the file. The file contains the
+// hex encoded key, see NewKey(). Decrypted values are private.
+func WithKeyFile(fsys fs.FS, path string) Option {
+	return func(b Builder, eb oferrors.Block) {
+		dat, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			eb.AddError(fmt.Errorf("cfg.WithKeyFile: %w", err))
+			return
+		}
+		key, err := hex.DecodeString(strings.TrimSpace(string(dat)))
+		if err != nil {
+			eb.AddError(fmt.Errorf("cfg.WithKeyFile: %v: %w", path, err))
+			return
+		}
+		WithKey(key)(b, eb)
+	}
+}
+
+// WithKey decrypts any encrypted values once all options
+// have run, using the AES key. Decrypted values are private.
+func WithKey(key []byte) Option {
+	return func(b Builder, eb oferrors.Block) {
+		if _, err := aes.NewCipher(key); err != nil {
+			eb.AddError(fmt.Errorf("cfg.WithKey: %w", err))
+			return
+		}
+		if sb, ok := b.(*_builder); ok {
+			sb.key = key
+		}
+	}
+}
+
+// NewKey answers a new random AES-256 key, hex encoded,
+// suitable for a key file.
+func NewKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// Encrypt answers the plaintext encrypted with AES-GCM, in the
+// format decrypted by WithKey() and WithKeyFile(). The answer
+// can be used as a string value in any settings file.
+func Encrypt(key []byte, plaintext string) (string, error) {
+	gcm, err := newGcm(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return EncryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt answers the plaintext of a value made by Encrypt().
+func Decrypt(key []byte, value string) (string, error) {
+	enc, ok := strings.CutPrefix(value, EncryptedPrefix)
+	if !ok {
+		return "", errors.New("value is not encrypted")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(enc)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGcm(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("encrypted value is too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func newGcm(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// decryptValues replaces every encrypted string in the tree
+// with its plaintext, answering the decrypted paths.
+func decryptValues(v any, base string, key []byte, eb oferrors.Block) []string {
+	var paths []string
+	decrypt := func(path, s string) (string, bool) {
+		if !strings.HasPrefix(s, EncryptedPrefix) {
+			return s, false
+		}
+		plaintext, err := Decrypt(key, s)
+		if err != nil {
+			eb.AddError(fmt.Errorf("%v: can't decrypt: %w", path, err))
+			return s, false
+		}
+		paths = append(paths, path)
+		return plaintext, true
+	}
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			path := joinPath(base, k)
+			if s, ok := child.(string); ok {
+				t[k], _ = decrypt(path, s)
+			} else {
+				paths = append(paths, decryptValues(child, path, key, eb)...)
+			}
+		}
+	case []any:
+		for i, child := range t {
+			path := joinPath(base, strconv.Itoa(i))
+			if s, ok := child.(string); ok {
+				t[i], _ = decrypt(path, s)
+			} else {
+				paths = append(paths, decryptValues(child, path, key, eb)...)
+			}
+		}
+	}
+	return paths
+}
+
+// isPrivate answers true if the path, or any parent, is private.
+func (s Settings) isPrivate(keys []string) bool {
+	for _, k := range keys {
+		if strings.HasPrefix(k, PrivatePrefix) {
+			return true
+		}
+	}
+	for _, pattern := range s.private {
+		pp := strings.Split(pattern, pathSeparator)
+		if len(pp) <= len(keys) && matchPath(pp, keys[:len(pp)]) {
+			return true
+		}
+	}
+	return false
+}
+
+// public answers a copy of the tree with every private value
+// replaced by the result of redact, or dropped if redact is nil.
+func (s Settings) public(redact func(any) any) tree {
+	var walk func(v any, keys []string) (any, bool)
+	walk = func(v any, keys []string) (any, bool) {
+		if len(keys) > 0 && s.isPrivate(keys) {
+			if redact == nil {
+				return nil, false
+			}
+			return redact(v), true
+		}
+		switch t := v.(type) {
+		case map[string]any:
+			m := make(map[string]any, len(t))
+			for k, child := range t {
+				if cv, ok := walk(child, append(keys, k)); ok {
+					m[k] = cv
+				}
+			}
+			return m, true
+		case []any:
+			list := make([]any, 0, len(t))
+			for i, child := range t {
+				if cv, ok := walk(child, append(keys, strconv.Itoa(i))); ok {
+					list = append(list, cv)
+				}
+			}
+			return list, true
+		}
+		return v, true
+	}
+	t, _ := walk(s.t, nil)
+	return t.(map[string]any)
+}
+
+// subsetPrivate answers the private paths that apply
+// below path, relative to it.
+func subsetPrivate(private []string, path string) []string {
+	p := splitPath(path)
+	var sub []string
+	for _, pattern := range private {
+		pp := strings.Split(pattern, pathSeparator)
+		if len(pp) > len(p) && matchPath(pp[:len(p)], p) {
+			sub = append(sub, strings.Join(pp[len(p):], pathSeparator))
+		} else if len(pp) <= len(p) && matchPath(pp, p[:len(pp)]) {
+			// The whole subset is private.
+			sub = append(sub, "*")
+		}
+	}
+	return sub
+}
+
+// redactChanges replaces the private values in the changes,
+// using the old settings for Old and the new for New.
+func redactChanges(old, s Settings, changes []Change) []Change {
+	for i, c := range changes {
+		keys := splitPath(c.Path)
+		if c.Old != nil && old.isPrivate(keys) {
+			changes[i].Old = RedactedValue
+		}
+		if c.New != nil && s.isPrivate(keys) {
+			changes[i].New = RedactedValue
+		}
+	}
+	return changes
+}
+
+func redactValue(v any) any {
+	return RedactedValue
+}
+
+const (
+	EncryptedPrefix = "enc:"
+	PrivatePrefix   = "_"
+	RedactedValue   = "<redacted>"
+)
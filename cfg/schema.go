@@ -0,0 +1,449 @@
+package cfg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Schema describes the keys a Settings is expected to contain.
+// A Schema can be declared in Go or loaded from JSON, see ReadSchema().
+type Schema struct {
+	Keys []SchemaKey `json:"keys"`
+
+	// AllowUnknown disables reporting settings that are
+	// not declared in the schema.
+	AllowUnknown bool `json:"allowUnknown,omitempty"`
+}
+
+// SchemaKey describes a single key in a Schema.
+type SchemaKey struct {
+	// Path to the key. A "*" component matches any map key or
+	// slice index, i.e. "users/*/name".
+	Path string `json:"path"`
+
+	// Type of the value, one of the Type constants. Values must
+	// convert with the same rules as the matching getter, so a
+	// TypeInt can't hold a fractional number, and a TypeBool can
+	// be a bool or the strings "true" or "t". The children of
+	// TypeMap, TypeSlice and TypeAny values are not checked
+	// unless other keys are declared below them.
+	Type string `json:"type,omitempty"`
+
+	Required bool `json:"required,omitempty"`
+
+	// Optional inclusive range. Numbers are compared by value,
+	// strings and slices by length.
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+
+	// Optional list of allowed values.
+	Enum []any `json:"enum,omitempty"`
+
+	// Documentation for the key.
+	Doc string `json:"doc,omitempty"`
+
+	// Private keys hold secrets, see WithPrivate().
+	Private bool `json:"private,omitempty"`
+
+	// Optional default, used by Defaults() and DefaultConfig().
+	Default any `json:"default,omitempty"`
+}
+
+const (
+	TypeAny    = "any"
+	TypeBool   = "bool"
+	TypeFloat  = "float"
+	TypeInt    = "int"
+	TypeMap    = "map"
+	TypeSlice  = "slice"
+	TypeString = "string"
+)
+
+// ReadSchema loads a JSON Schema from the file system.
+func ReadSchema(fsys fs.FS, path string) (Schema, error) {
+	schema := Schema{}
+	dat, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return schema, err
+	}
+	err = json.Unmarshal(dat, &schema)
+	return schema, err
+}
+
+// Validate checks the settings against the schema, answering an
+// error that describes every problem. Each problem is prefixed
+// with the path to the setting.
+func (s Settings) Validate(schema Schema) error {
+	var errs []error
+	for _, key := range schema.Keys {
+		errs = append(errs, key.validate(s)...)
+	}
+	if !schema.AllowUnknown {
+		for _, p := range leafPaths(s.t, "") {
+			if !schema.declares(p) {
+				errs = append(errs, fmt.Errorf("%v: unknown setting", p))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (k SchemaKey) validate(s Settings) []error {
+	p := strings.Split(strings.Trim(k.Path, pathSeparator), pathSeparator)
+	var errs []error
+	if k.Required {
+		// Wildcard keys are only required where their parent exists.
+		last := len(p) - 1
+		parents := []pathMatch{{value: s.t}}
+		if last > 0 {
+			parents = expandPath(s.t, p[:last], "")
+		}
+		if strings.Contains(k.Path, "*") {
+			parents = slices.DeleteFunc(parents, func(m pathMatch) bool {
+				_, ok := m.value.(map[string]any)
+				return !ok
+			})
+		}
+		for _, parent := range parents {
+			if len(expandPath(parent.value, p[last:], "")) < 1 {
+				errs = append(errs, fmt.Errorf("%v: missing required setting", joinPath(parent.path, p[last])))
+			}
+		}
+		if len(parents) < 1 && !strings.Contains(k.Path, "*") {
+			errs = append(errs, fmt.Errorf("%v: missing required setting", strings.Join(p, pathSeparator)))
+		}
+	}
+	matches := expandPath(s.t, p, "")
+	for _, m := range matches {
+		if err := k.validateValue(m.value); err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", m.path, err))
+		}
+	}
+	return errs
+}
+
+func (k SchemaKey) validateValue(v any) error {
+	s := Settings{t: tree{leafKey: v}}
+	size := math.NaN()
+	switch k.Type {
+	case "", TypeAny:
+		if f, ok := leafFloat64(s, leafKey); ok {
+			size = f
+		}
+	case TypeBool:
+		if _, ok := leafBool(s, leafKey); !ok {
+			return fmt.Errorf("has value %v but wants a bool", formatValue(v))
+		}
+	case TypeFloat:
+		f, ok := leafFloat64(s, leafKey)
+		if !ok {
+			return fmt.Errorf("has value %v but wants a float", formatValue(v))
+		}
+		size = f
+	case TypeInt:
+		f, ok := leafFloat64(s, leafKey)
+		if !ok || f != math.Trunc(f) {
+			return fmt.Errorf("has value %v but wants an int", formatValue(v))
+		}
+		size = f
+	case TypeString:
+		str, ok := leafString(s, leafKey)
+		if !ok {
+			return fmt.Errorf("has value %v but wants a string", formatValue(v))
+		}
+		size = float64(len(str))
+	case TypeSlice:
+		list, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("has value %v but wants a slice", formatValue(v))
+		}
+		size = float64(len(list))
+	case TypeMap:
+		if _, ok := v.(map[string]any); !ok {
+			return fmt.Errorf("has value %v but wants a map", formatValue(v))
+		}
+	default:
+		return fmt.Errorf("unknown schema type \"%v\"", k.Type)
+	}
+	if !math.IsNaN(size) {
+		if k.Min != nil && size < *k.Min {
+			return fmt.Errorf("has value %v but wants at least %v", formatValue(v), *k.Min)
+		}
+		if k.Max != nil && size > *k.Max {
+			return fmt.Errorf("has value %v but wants at most %v", formatValue(v), *k.Max)
+		}
+	}
+	if len(k.Enum) > 0 {
+		want := fmt.Sprintf("%v", v)
+		if !slices.ContainsFunc(k.Enum, func(e any) bool { return fmt.Sprintf("%v", e) == want }) {
+			return fmt.Errorf("has value %v but wants one of %v", formatValue(v), formatValue(k.Enum))
+		}
+	}
+	return nil
+}
+
+// declares answers true if the leaf path is described
+// by a key, lies along the path of a key, or lies below
+// a key with children that aren't checked.
+func (sc Schema) declares(path string) bool {
+	p := strings.Split(path, pathSeparator)
+	for _, key := range sc.Keys {
+		kp := strings.Split(strings.Trim(key.Path, pathSeparator), pathSeparator)
+		if len(kp) > len(p) {
+			// An empty map or slice along the path of a key.
+			if matchPath(kp[:len(p)], p) {
+				return true
+			}
+			continue
+		} else if !matchPath(kp, p[:len(kp)]) {
+			continue
+		}
+		if len(kp) == len(p) {
+			return true
+		}
+		switch key.Type {
+		case "", TypeAny, TypeMap, TypeSlice:
+			if !sc.hasChildren(kp) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasChildren answers true if any key is declared below
+// the path, in which case the path's children are checked.
+func (sc Schema) hasChildren(p []string) bool {
+	for _, key := range sc.Keys {
+		kp := strings.Split(strings.Trim(key.Path, pathSeparator), pathSeparator)
+		if len(kp) > len(p) && slices.Equal(kp[:len(p)], p) {
+			return true
+		}
+	}
+	return false
+}
+
+// PrivatePaths answers the path of every private key,
+// for use with WithPrivate().
+func (sc Schema) PrivatePaths() []string {
+	var paths []string
+	for _, k := range sc.Keys {
+		if k.Private {
+			paths = append(paths, k.Path)
+		}
+	}
+	return paths
+}
+
+// Defaults answers a Settings with the default value of each key.
+// Keys with wildcards or without a default are skipped.
+func (sc Schema) Defaults() Settings {
+	s := emptySettings()
+	for _, key := range sc.Keys {
+		if key.Default != nil && !strings.Contains(key.Path, "*") {
+			setPath(s.t, key.Path, key.Default)
+		}
+	}
+	return s
+}
+
+// DefaultConfig answers a documented config file for the schema.
+// Every key without a wildcard is included, with its default or
+// the zero value of its type, and each key's doc, type and
+// constraints are written as a comment above it. The result is
+// JSON with comments.
+func (sc Schema) DefaultConfig() []byte {
+	t := make(tree)
+	docs := make(map[string]SchemaKey)
+	for _, key := range sc.Keys {
+		if strings.Contains(key.Path, "*") {
+			continue
+		}
+		path := strings.Trim(key.Path, pathSeparator)
+		docs[path] = key
+		v := key.Default
+		if v == nil {
+			v = zeroValue(key.Type)
+		}
+		// Don't replace a map created by a child key.
+		if _, ok := v.(map[string]any); ok {
+			if _, exists := (Settings{t: t}).lookup(path); exists {
+				continue
+			}
+		}
+		setPath(t, path, v)
+	}
+	sb := &strings.Builder{}
+	writeDocumented(sb, t, docs, "", 0)
+	sb.WriteString("\n")
+	return []byte(sb.String())
+}
+
+func writeDocumented(sb *strings.Builder, t tree, docs map[string]SchemaKey, base string, depth int) {
+	indent := strings.Repeat("  ", depth)
+	sb.WriteString("{\n")
+	keys := slices.Sorted(maps.Keys(t))
+	for i, k := range keys {
+		path := joinPath(base, k)
+		if key, ok := docs[path]; ok {
+			for _, line := range key.comment() {
+				sb.WriteString(indent + "  // " + line + "\n")
+			}
+		}
+		sb.WriteString(indent + "  " + strconv.Quote(k) + ": ")
+		if sub, ok := t[k].(map[string]any); ok && len(sub) > 0 {
+			writeDocumented(sb, sub, docs, path, depth+1)
+		} else {
+			dat, _ := json.Marshal(t[k])
+			sb.Write(dat)
+		}
+		if i < len(keys)-1 {
+			sb.WriteString(",")
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString(indent + "}")
+}
+
+// comment answers the documentation lines for the key.
+func (k SchemaKey) comment() []string {
+	var lines []string
+	if k.Doc != "" {
+		lines = append(lines, strings.Split(k.Doc, "\n")...)
+	}
+	var info []string
+	if k.Type != "" {
+		info = append(info, k.Type)
+	}
+	if k.Required {
+		info = append(info, "required")
+	}
+	if k.Min != nil {
+		info = append(info, fmt.Sprintf("min %v", *k.Min))
+	}
+	if k.Max != nil {
+		info = append(info, fmt.Sprintf("max %v", *k.Max))
+	}
+	if len(k.Enum) > 0 {
+		info = append(info, fmt.Sprintf("one of %v", formatValue(k.Enum)))
+	}
+	if len(info) > 0 {
+		lines = append(lines, "("+strings.Join(info, ", ")+")")
+	}
+	return lines
+}
+
+type pathMatch struct {
+	path  string
+	value any
+}
+
+// expandPath answers every value that matches the path,
+// where "*" matches any map key or slice index.
+func expandPath(v any, p []string, base string) []pathMatch {
+	if len(p) < 1 || (len(p) == 1 && p[0] == "") {
+		return []pathMatch{{path: base, value: v}}
+	}
+	var matches []pathMatch
+	switch t := v.(type) {
+	case map[string]any:
+		if p[0] == "*" {
+			for _, k := range slices.Sorted(maps.Keys(t)) {
+				matches = append(matches, expandPath(t[k], p[1:], joinPath(base, k))...)
+			}
+		} else if child, ok := t[p[0]]; ok {
+			matches = append(matches, expandPath(child, p[1:], joinPath(base, p[0]))...)
+		}
+	case []any:
+		if p[0] == "*" {
+			for i, child := range t {
+				matches = append(matches, expandPath(child, p[1:], joinPath(base, strconv.Itoa(i)))...)
+			}
+		} else if i, err := strconv.Atoi(p[0]); err == nil && i >= 0 && i < len(t) {
+			matches = append(matches, expandPath(t[i], p[1:], joinPath(base, p[0]))...)
+		}
+	}
+	return matches
+}
+
+func matchPath(pattern, p []string) bool {
+	for i, n := range pattern {
+		if n != "*" && n != p[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// leafPaths answers the path to every leaf in the tree, in
+// sorted order. Empty maps and slices count as leaves.
+func leafPaths(v any, base string) []string {
+	var paths []string
+	switch t := v.(type) {
+	case map[string]any:
+		if len(t) < 1 && base != "" {
+			return []string{base}
+		}
+		for k, child := range t {
+			paths = append(paths, leafPaths(child, joinPath(base, k))...)
+		}
+	case []any:
+		if len(t) < 1 {
+			return []string{base}
+		}
+		for i, child := range t {
+			paths = append(paths, leafPaths(child, joinPath(base, strconv.Itoa(i)))...)
+		}
+	default:
+		return []string{base}
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// setPath assigns the value in the tree, creating maps as needed.
+func setPath(t tree, path string, v any) {
+	p := strings.Split(strings.Trim(path, pathSeparator), pathSeparator)
+	for _, n := range p[:len(p)-1] {
+		sub, ok := t[n].(map[string]any)
+		if !ok {
+			sub = make(map[string]any)
+			t[n] = sub
+		}
+		t = sub
+	}
+	t[p[len(p)-1]] = v
+}
+
+func zeroValue(typ string) any {
+	switch typ {
+	case TypeBool:
+		return false
+	case TypeFloat, TypeInt:
+		return 0
+	case TypeString:
+		return ""
+	case TypeSlice:
+		return []any{}
+	case TypeMap:
+		return map[string]any{}
+	}
+	return nil
+}
+
+func formatValue(v any) string {
+	sb := strings.Builder{}
+	enc := json.NewEncoder(&sb)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err == nil {
+		return strings.TrimSuffix(sb.String(), "\n")
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -24,24 +24,42 @@ type Settings struct {
 	// slice. For the client, the key that got us to the slice
 	// has disappeared, but we are a map and need a key, so it gets saved here.
 	sliceKey string
+	// src is the source of each value, by path.
+	src map[string]Source
+	// private are the paths of private values, see WithPrivate().
+	private []string
 }
 
 func NewSettings(opts ...Option) (Settings, error) {
+	s, _, err := newSettings(opts...)
+	return s, err
+}
+
+// newSettings answers the new settings along with the builder
+// that made them, for clients that need the build state.
+func newSettings(opts ...Option) (Settings, *_builder, error) {
 	s := emptySettings()
 	eb := &oferrors.FirstBlock{}
 	builder := &_builder{t: s.t}
-	for _, opt := range opts {
+	for i, opt := range opts {
 		if opt != nil {
+			builder.startOption(i)
 			opt(builder, eb)
 		}
 	}
-	return s, eb.Err
+	if builder.key != nil {
+		builder.private = append(builder.private, decryptValues(s.t, "", builder.key, eb)...)
+	}
+	s.src = builder.src
+	s.private = builder.private
+	return s, builder, eb.Err
 }
 
 // SaveSettings saves the settings as JSON to the path.
-// It will remove any private keys.
+// It will remove any private keys, including every key
+// that starts with PrivatePrefix ("_"), see WithPrivate().
 func SaveSettings(path string, s Settings) error {
-	b, err := s.asJson()
+	b, err := WriteJson(s)
 	if err != nil {
 		return err
 	}
@@ -49,8 +67,10 @@ func SaveSettings(path string, s Settings) error {
 	return err
 }
 
+// WriteJson answers the settings as JSON.
+// It will remove any private keys, see SaveSettings().
 func WriteJson(s Settings) ([]byte, error) {
-	return s.asJson()
+	return json.Marshal(s.public(nil))
 }
 
 // String answers the string value at the given path.
@@ -179,7 +199,9 @@ func (s Settings) flatBoolList(p string) (bool, bool) {
 // walking down the path. The path can have components
 // separated with "/".
 func (s Settings) Subset(path string) Settings {
-	return s.lockedSubset(path)
+	sub := s.lockedSubset(path)
+	sub.private = subsetPrivate(s.private, path)
+	return sub
 }
 
 // lockedSubset answers a subset of the settings tree based on
@@ -245,15 +267,27 @@ func (s Settings) AllKeys() iter.Seq[string] {
 	}
 }
 
+// Tree answers a copy of the settings as a tree of map[string]any,
+// []any and scalars, as decoded by encoding/json. A slice subset
+// answers the slice. This allows tools like jacl.RunTree()
+// to walk the settings.
+func (s Settings) Tree() any {
+	v, _ := s.lookup("")
+	return cloneValue(v)
+}
+
 func (s Settings) asJson() ([]byte, error) {
 	b, err := json.Marshal(s.t)
 	return b, err
 }
 
+// Print writes the settings to stdout, with
+// private values redacted.
 func (s Settings) Print() {
-	if d, err := json.MarshalIndent(s.t, "", "  "); err == nil {
-		fmt.Println(string(d))
-	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	enc.Encode(s.public(redactValue))
 }
 
 type getFlatTypeFunc[T any] func(s Settings, path string) (T, bool)
@@ -382,6 +416,56 @@ func leafString(s Settings, p string) (string, bool) {
 	return "", false
 }
 
+// lookup answers the raw value at the path, indexing into
+// maps by key and slices by index. An empty path answers the root.
+func (s Settings) lookup(path string) (any, bool) {
+	var v any = s.t
+	if s.sliceKey != "" {
+		v = s.t[s.sliceKey]
+	}
+	for _, n := range strings.Split(path, pathSeparator) {
+		if n == "" {
+			continue
+		}
+		switch t := v.(type) {
+		case map[string]any:
+			var ok bool
+			if v, ok = t[n]; !ok {
+				return nil, false
+			}
+		case []any:
+			i, err := strconv.Atoi(n)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil, false
+			}
+			v = t[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// cloneValue makes a deep copy of the maps and slices in v.
+func cloneValue(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(t))
+		for k, tv := range t {
+			m[k] = cloneValue(tv)
+		}
+		return m
+	case []any:
+		s := make([]any, len(t))
+		for i, tv := range t {
+			s[i] = cloneValue(tv)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
 // pathIndex looks at an index in a path slice and returns it
 // as an int, if it converts.
 func pathIndex(index int, path []string) (int, bool) {
@@ -0,0 +1,128 @@
+package cfg
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Source describes where a setting came from.
+type Source struct {
+	// The kind of source, one of the Source constants.
+	Kind string
+
+	// The file name for SourceFile, the env var name
+	// for SourceEnv, or the settings path for SourceArg.
+	Name string
+
+	// The JSON pointer to the value in the file,
+	// for SourceFile ("/run/count").
+	Pointer string
+
+	// The index of the Option in NewSettings().
+	Index int
+}
+
+func (s Source) String() string {
+	switch s.Kind {
+	case SourceFile:
+		return s.Name + "#" + s.Pointer
+	case SourceArg:
+		return "--" + s.Name
+	case SourceEnv:
+		return "$" + s.Name
+	case SourceOption:
+		return fmt.Sprintf("option %v", s.Index)
+	default:
+		return fmt.Sprintf("%v option %v", s.Kind, s.Index)
+	}
+}
+
+// Source answers the source of the value at path. Values
+// below a slice answer the source of the slice, since slices
+// are replaced as a whole when settings are merged. Only
+// Settings made with NewSettings() have sources; subsets
+// and other derived Settings don't.
+func (s Settings) Source(path string) (Source, bool) {
+	if _, ok := s.lookup(path); !ok || len(s.src) < 1 {
+		return Source{}, false
+	}
+	p := strings.Trim(path, pathSeparator)
+	for p != "" {
+		if src, ok := s.src[p]; ok {
+			return src, true
+		}
+		p = p[:max(strings.LastIndex(p, pathSeparator), 0)]
+	}
+	return Source{}, false
+}
+
+// Explain answers a description of every value and its
+// source, one per line, sorted by path. Private values
+// are redacted:
+//
+//	run/count = 10  (a.json#/run/count)
+//	run/debug = "true"  ($APP_RUN_DEBUG)
+func (s Settings) Explain() string {
+	sb := strings.Builder{}
+	for _, path := range sourcePaths(s.t, "") {
+		v, _ := s.lookup(path)
+		if s.isPrivate(splitPath(path)) {
+			v = RedactedValue
+		}
+		src := "unknown"
+		if source, ok := s.Source(path); ok {
+			src = source.String()
+		}
+		fmt.Fprintf(&sb, "%v = %v  (%v)\n", path, formatValue(v), src)
+	}
+	return sb.String()
+}
+
+// sourcePaths answers the path to every value that is
+// replaced as a whole during a merge, in sorted order.
+// Unlike leafPaths, slices are not descended.
+func sourcePaths(t tree, base string) []string {
+	var paths []string
+	for _, k := range slices.Sorted(maps.Keys(t)) {
+		path := joinPath(base, k)
+		if m, ok := t[k].(map[string]any); ok && len(m) > 0 {
+			paths = append(paths, sourcePaths(m, path)...)
+		} else {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// sourceFunc answers the source for the value at keys.
+type sourceFunc func(keys []string) Source
+
+// setSource assigns the source used for the settings
+// the option adds next.
+func setSource(b Builder, fn sourceFunc) {
+	if sb, ok := b.(*_builder); ok {
+		sb.source = fn
+	}
+}
+
+// jsonPointer answers the RFC 6901 pointer to the keys.
+func jsonPointer(keys []string) string {
+	sb := strings.Builder{}
+	for _, k := range keys {
+		sb.WriteString("/")
+		sb.WriteString(jsonPointerEscaper.Replace(k))
+	}
+	return sb.String()
+}
+
+var jsonPointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+const (
+	SourceArg    = "arg"
+	SourceEnv    = "env"
+	SourceFile   = "file"
+	SourceMap    = "map"
+	SourceOption = "option"
+)
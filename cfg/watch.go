@@ -0,0 +1,208 @@
+package cfg
+
+import (
+	"fmt"
+	"io/fs"
+	"maps"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hackborn/onefunc/msg"
+	"github.com/hackborn/onefunc/sync"
+)
+
+// Watcher provides a live Settings. It polls the files read
+// by the Options (currently any WithFS option) and, when one
+// changes, re-runs the Options and swaps in the new Settings.
+// Subscribers are notified of every leaf path that changed.
+//
+// Watcher is safe for concurrent use.
+type Watcher struct {
+	opts     []Option
+	settings atomic.Pointer[Settings]
+
+	mu      sync.Mutex
+	sources []watchSource
+	stamp   string
+	nextId  int64
+	subs    map[int64]WatchFunc
+	routers []watchRouter
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// WatchFunc receives the new settings and the changes that
+// produced them.
+type WatchFunc func(s Settings, changes []Change)
+
+// Change describes a single leaf that changed between two Settings.
+// Old is nil for added paths and New is nil for removed paths.
+// Private values (see WithPrivate()) are replaced by RedactedValue.
+type Change struct {
+	Path string
+	Old  any
+	New  any
+}
+
+// NewWatcher answers a new Watcher on the options. The initial
+// Settings is built immediately; polling doesn't begin until Start().
+func NewWatcher(opts ...Option) (*Watcher, error) {
+	w := &Watcher{opts: opts, subs: make(map[int64]WatchFunc)}
+	s, b, err := newSettings(opts...)
+	w.settings.Store(&s)
+	w.sources = b.sources
+	w.stamp = stampSources(w.sources)
+	return w, err
+}
+
+// Settings answers the current settings.
+func (w *Watcher) Settings() Settings {
+	return *w.settings.Load()
+}
+
+// Subscribe adds a function that is called after each reload
+// that changes the settings. Use the answered subscription to
+// unsubscribe.
+func (w *Watcher) Subscribe(fn WatchFunc) msg.Subscription {
+	defer sync.Lock(&w.mu).Unlock()
+	w.nextId++
+	w.subs[w.nextId] = fn
+	return &watchSubscription{w: w, id: w.nextId}
+}
+
+// Publish sends every change to the router as a Change, on
+// a topic made from the prefix and the changed path. For
+// example, with prefix "cfg" a change to "net/port" is
+// published to "cfg/net/port", so clients can subscribe
+// to "cfg/net/#".
+func (w *Watcher) Publish(r *msg.Router, prefix string) {
+	defer sync.Lock(&w.mu).Unlock()
+	w.routers = append(w.routers, watchRouter{r: r, prefix: strings.Trim(prefix, pathSeparator)})
+}
+
+// Start begins polling the sources at the interval.
+// Errors while reloading are ignored, and the current
+// settings are kept. Call Close() to stop polling.
+func (w *Watcher) Start(interval time.Duration) {
+	defer sync.Lock(&w.mu).Unlock()
+	if w.stop != nil {
+		return
+	}
+	w.stop, w.done = make(chan struct{}), make(chan struct{})
+	go w.poll(interval, w.stop, w.done)
+}
+
+// Close stops polling.
+func (w *Watcher) Close() {
+	w.mu.Lock()
+	stop, done := w.stop, w.done
+	w.stop, w.done = nil, nil
+	w.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+func (w *Watcher) poll(interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			w.Poll()
+		}
+	}
+}
+
+// Poll checks the sources once, reloading the settings if
+// any have changed. It answers the changes, which are empty
+// if nothing was reloaded or the reload produced the same
+// settings. On error the current settings are kept.
+func (w *Watcher) Poll() ([]Change, error) {
+	w.mu.Lock()
+	stamp := stampSources(w.sources)
+	if stamp == w.stamp {
+		w.mu.Unlock()
+		return nil, nil
+	}
+	s, b, err := newSettings(w.opts...)
+	if err != nil {
+		w.mu.Unlock()
+		return nil, fmt.Errorf("cfg.Watcher: %w", err)
+	}
+	w.stamp = stamp
+	w.sources = b.sources
+	old := w.settings.Swap(&s)
+	changes := redactChanges(*old, s, diffLeaves(*old, s))
+	subs := make([]WatchFunc, 0, len(w.subs))
+	for _, id := range slices.Sorted(maps.Keys(w.subs)) {
+		subs = append(subs, w.subs[id])
+	}
+	routers := slices.Clone(w.routers)
+	w.mu.Unlock()
+
+	// Notify outside the lock, so subscribers can use the watcher.
+	if len(changes) < 1 {
+		return nil, nil
+	}
+	for _, fn := range subs {
+		fn(s, changes)
+	}
+	for _, r := range routers {
+		for _, c := range changes {
+			msg.Pub(r.r, joinPath(r.prefix, c.Path), c)
+		}
+	}
+	return changes, nil
+}
+
+type watchSubscription struct {
+	w  *Watcher
+	id int64
+}
+
+func (s *watchSubscription) Unsub() {
+	defer sync.Lock(&s.w.mu).Unlock()
+	delete(s.w.subs, s.id)
+}
+
+type watchRouter struct {
+	r      *msg.Router
+	prefix string
+}
+
+// watchSource is a file system pattern read by an Option.
+type watchSource struct {
+	fsys    fs.FS
+	pattern string
+}
+
+// stampSources answers a description of the current state of
+// every file matching the sources. Any change to the set of
+// files, their sizes or mod times changes the stamp.
+func stampSources(sources []watchSource) string {
+	sb := strings.Builder{}
+	for _, src := range sources {
+		matches, err := fs.Glob(src.fsys, src.pattern)
+		if err != nil {
+			fmt.Fprintf(&sb, "%v: %v\n", src.pattern, err)
+			continue
+		}
+		for _, match := range matches {
+			info, err := fs.Stat(src.fsys, match)
+			if err != nil {
+				fmt.Fprintf(&sb, "%v: %v\n", match, err)
+				continue
+			}
+			fmt.Fprintf(&sb, "%v %v %v\n", match, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return sb.String()
+}
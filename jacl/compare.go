@@ -0,0 +1,241 @@
+package jacl
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// compareOp is a comparison operator.
+type compareOp string
+
+const (
+	opEq       compareOp = "="
+	opNe       compareOp = "!="
+	opLt       compareOp = "<"
+	opLe       compareOp = "<="
+	opGt       compareOp = ">"
+	opGe       compareOp = ">="
+	opMatch    compareOp = "~="
+	opPrefix   compareOp = "^="
+	opSuffix   compareOp = "$="
+	opContains compareOp = "*="
+)
+
+// compareValue is the parsed value side of a term.
+type compareValue struct {
+	text string
+	// tolerance is only valid if hasTolerance is true.
+	tolerance    float64
+	hasTolerance bool
+	// re is the compiled regular expression for "~=".
+	re *regexp.Regexp
+}
+
+// handleCompare compares the target against the value.
+func (r *runner) handleCompare(target any, op compareOp, want compareValue) error {
+	ok, err := r.compare(target, op, want)
+	if err != nil {
+		r.fail(Failure{Path: r.nodes[0].path, Value: target, HasValue: true, Message: err.Error()})
+		return fmt.Errorf("Term \"%v\" %w", r.currentTerm, err)
+	}
+	if ok {
+		return nil
+	}
+	r.fail(Failure{Path: r.nodes[0].path, Value: target, HasValue: true, Message: wants(op, want)})
+	return fmt.Errorf("Term \"%v\" %v", r.currentTerm, mismatch(target, op, want))
+}
+
+// mismatch describes a failed comparison.
+func mismatch(target any, op compareOp, want compareValue) string {
+	return fmt.Sprintf("has value \"%v\" but %v", target, wants(op, want))
+}
+
+// wants describes the value a comparison wants.
+func wants(op compareOp, want compareValue) string {
+	if op == opEq {
+		return fmt.Sprintf("wants \"%v\"", want.text)
+	}
+	return fmt.Sprintf("wants %v \"%v\"", op, want.text)
+}
+
+func (r *runner) compare(target any, op compareOp, want compareValue) (bool, error) {
+	switch op {
+	case opMatch:
+		return want.re.MatchString(formatTarget(target)), nil
+	case opPrefix:
+		return strings.HasPrefix(formatTarget(target), r.opts.processValue(want.text)), nil
+	case opSuffix:
+		return strings.HasSuffix(formatTarget(target), r.opts.processValue(want.text)), nil
+	case opContains:
+		return strings.Contains(formatTarget(target), r.opts.processValue(want.text)), nil
+	}
+	c, err := r.order(target, op, want)
+	if err != nil {
+		return false, err
+	}
+	switch op {
+	case opEq:
+		return c == 0, nil
+	case opNe:
+		return c != 0, nil
+	case opLt:
+		return c < 0, nil
+	case opLe:
+		return c <= 0, nil
+	case opGt:
+		return c > 0, nil
+	case opGe:
+		return c >= 0, nil
+	}
+	return false, fmt.Errorf("has unknown comparison %v", op)
+}
+
+// order answers the comparison of the target to the value:
+// -1 if the target is less, 0 if it's equal, 1 if it's greater.
+// Bools can only be tested for equality.
+func (r *runner) order(target any, op compareOp, want compareValue) (int, error) {
+	tv := reflect.ValueOf(target)
+	switch cmpTarget := target.(type) {
+	case bool:
+		if op != opEq && op != opNe {
+			return 0, fmt.Errorf("can't order bool with %v", op)
+		}
+		s := strings.ToLower(want.text)
+		if cmpTarget == true && (s == "t" || s == "true") {
+			return 0, nil
+		} else if cmpTarget == false && (s == "f" || s == "false") {
+			return 0, nil
+		}
+		return 1, nil
+	case string:
+		return strings.Compare(cmpTarget, r.opts.processValue(want.text)), nil
+	}
+	a, ok := valueNumber(tv)
+	if n, isJSON := target.(json.Number); isJSON {
+		a, ok = parseNumber(n.String())
+	}
+	if ok {
+		b, ok := parseNumber(want.text)
+		_, isStringer := target.(fmt.Stringer)
+		if ok && b.kind == floatNumber && tv.Kind() == reflect.Float32 {
+			// Compare at the target's precision, so literals
+			// like 0.1 equal the float32 they were assigned to.
+			b.f = float64(float32(b.f))
+		}
+		if ok {
+			tolerance := r.opts.Tolerance
+			if want.hasTolerance {
+				tolerance = want.tolerance
+			}
+			return compareNumbers(a, b, tolerance), nil
+		} else if !isStringer {
+			return 0, fmt.Errorf("can't compare %v %v to \"%v\"", tv.Kind(), target, want.text)
+		}
+	}
+	// Not sure if this is the best way to handle this, but for unknown types
+	// convert them to string and compare. It's the only way I can think of
+	// to handle custom types like bitmasks.
+	return strings.Compare(formatTarget(target), want.text), nil
+}
+
+// ---------------------------------------------------------
+// NUMBERS
+
+type numberKind int
+
+const (
+	intNumber numberKind = iota
+	uintNumber
+	floatNumber
+)
+
+// number is any int, uint or float, stored without
+// losing precision.
+type number struct {
+	kind numberKind
+	i    int64
+	u    uint64
+	f    float64
+}
+
+func (n number) float() float64 {
+	switch n.kind {
+	case intNumber:
+		return float64(n.i)
+	case uintNumber:
+		return float64(n.u)
+	default:
+		return n.f
+	}
+}
+
+// valueNumber answers the number in v, if it is
+// an int, uint or float kind.
+func valueNumber(v reflect.Value) (number, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return number{kind: intNumber, i: v.Int()}, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return number{kind: uintNumber, u: v.Uint()}, true
+	case reflect.Float32, reflect.Float64:
+		return number{kind: floatNumber, f: v.Float()}, true
+	}
+	return number{}, false
+}
+
+// parseNumber answers the number in s, preferring
+// an int, then a uint, then a float.
+func parseNumber(s string) (number, bool) {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return number{kind: intNumber, i: i}, true
+	}
+	if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return number{kind: uintNumber, u: u}, true
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return number{kind: floatNumber, f: f}, true
+	}
+	return number{}, false
+}
+
+// compareNumbers answers -1, 0 or 1 as a is less than, equal
+// to or greater than b. Ints and uints are compared exactly,
+// floats are equal if they are within the tolerance.
+func compareNumbers(a, b number, tolerance float64) int {
+	switch {
+	case a.kind == floatNumber || b.kind == floatNumber:
+		af, bf := a.float(), b.float()
+		if math.Abs(af-bf) <= tolerance {
+			return 0
+		}
+		return cmp.Compare(af, bf)
+	case a.kind == intNumber && b.kind == intNumber:
+		return cmp.Compare(a.i, b.i)
+	case a.kind == uintNumber && b.kind == uintNumber:
+		return cmp.Compare(a.u, b.u)
+	case a.kind == intNumber:
+		if a.i < 0 {
+			return -1
+		}
+		return cmp.Compare(uint64(a.i), b.u)
+	default:
+		if b.i < 0 {
+			return 1
+		}
+		return cmp.Compare(a.u, uint64(b.i))
+	}
+}
+
+// formatTarget answers the target as a string.
+func formatTarget(target any) string {
+	if s, ok := target.(string); ok {
+		return s
+	}
+	return fmt.Sprintf("%v", target)
+}
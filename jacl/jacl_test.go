@@ -2,9 +2,151 @@ package jacl
 
 import (
 	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
+// ---------------------------------------------------------
+// TEST-COMPARE
+func TestCompare(t *testing.T) {
+	// A variable, so the sum isn't an exact constant.
+	tenth := 0.1
+	table := []struct {
+		opts    Opts
+		dst     any
+		exprs   []string
+		wantErr error
+	}{
+		// NOT EQUAL
+		{Opts{}, Field{Name: "a"}, []string{`Name!=b`, `IV!=1`, `BV!=true`}, nil},
+		{Opts{}, Field{Name: "a"}, []string{`Name!=a`}, fmt.Errorf(`Term "Name!=a" has value "a" but wants != "a"`)},
+		// ORDERED
+		{Opts{}, Field{IV: 5}, []string{`IV<6`, `IV<=5`, `IV>4`, `IV>=5`, `IV>-1`, `IV > -1`}, nil},
+		{Opts{}, Field{IV: -5}, []string{`IV<0`, `IV=-5`, `IV>=-5`}, nil},
+		{Opts{}, Field{Name: "b"}, []string{`Name<c`, `Name>a`, `Name>=b`}, nil},
+		{Opts{}, Field{IV: 5}, []string{`IV<5`}, fmt.Errorf(`Term "IV<5" has value "5" but wants < "5"`)},
+		{Opts{}, Field{BV: true}, []string{`BV<true`}, fmt.Errorf(`Term "BV<true" can't order bool with <`)},
+		// NUMBERS ACROSS KINDS
+		{Opts{}, Numbers{I8: -3, U64: math.MaxUint64, F32: 1.5, F64: tenth + 0.2}, []string{`I8=-3`, `I8<1`, `U64=18446744073709551615`, `U64>-1`, `U64>9223372036854775807`, `F32=1.5`, `F32>1`, `F32<2.0`}, nil},
+		{Opts{}, Numbers{F32: 0.1, F64: 0.1}, []string{`F32=0.1`, `F32!=0.2`, `F32<=0.1`, `F32>=0.1`, `F64=0.1`}, nil},
+		{Opts{}, Numbers{F32: 16777216}, []string{`F32<16777217`, `F32=16777216`}, nil},
+		{Opts{}, Numbers{U8: 200}, []string{`U8=200.0`, `U8>199.5`, `U8<200.5`}, nil},
+		{Opts{}, Numbers{I8: 1}, []string{`I8=a`}, fmt.Errorf(`Term "I8=a" can't compare int8 1 to "a"`)},
+		// FLOAT TOLERANCE
+		{Opts{}, Numbers{F64: tenth + 0.2}, []string{`F64=0.3`}, fmt.Errorf(`Term "F64=0.3" has value "0.30000000000000004" but wants "0.3"`)},
+		{Opts{}, Numbers{F64: tenth + 0.2}, []string{`F64=0.3~0.0001`, `F64=3e-1~1e-9`, `F64!=0.31~0.001`}, nil},
+		{Opts{Tolerance: 0.0001}, Numbers{F64: tenth + 0.2}, []string{`F64=0.3`, `F64<=0.3`, `F64>=0.3`}, nil},
+		{Opts{}, Numbers{F64: 1.5}, []string{`F64=1.5~`}, fmt.Errorf(`expr "F64=1.5~" has tolerance without a number`)},
+		// STRINGS
+		{Opts{}, Field{Name: "apple pie"}, []string{`Name^=apple`, `Name$=pie`, `Name*="le p"`, `Name~="^a.*e$"`}, nil},
+		{Opts{}, Field{Name: "a12"}, []string{"Name~=`^a\\d+$`"}, nil},
+		{Opts{}, Field{IV: 123}, []string{`IV^=12`, `IV~="3$"`}, nil},
+		{Opts{}, Field{Name: "apple"}, []string{`Name^=pie`}, fmt.Errorf(`Term "Name^=pie" has value "apple" but wants ^= "pie"`)},
+		{Opts{}, Field{Name: "apple"}, []string{`Name~="("`}, fmt.Errorf("bad regexp")},
+		// CUSTOM TYPES
+		{Opts{}, Numbers{Flag: flagB}, []string{`Flag=b`, `Flag=2`, `Flag!=a`}, nil},
+		// SYNTAX
+		{Opts{}, Field{Name: "a"}, []string{`Name=a b`}, fmt.Errorf(`expr "Name=a b" contains tokens past the comparison (b)`)},
+		{Opts{}, Field{Name: "a"}, []string{`Name==a`}, fmt.Errorf(`expr "Name==a" contains tokens past the comparison (a)`)},
+	}
+	for i, v := range table {
+		haveErr := RunOpts(v.opts, v.dst, v.exprs...)
+		if err := RunErr(haveErr, v.wantErr); err != nil {
+			t.Fatalf("TestCompare %v %v", i, err)
+		}
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-COMPILE
+func TestCompile(t *testing.T) {
+	table := []struct {
+		terms []string
+		dst   any
+		// wantErr is contained in the error.
+		wantErr error
+	}{
+		// BOOLEAN
+		{[]string{`Name=a || Name=b`}, Field{Name: "b"}, nil},
+		{[]string{`Name=a&&IV=1`, `Name=a && !(IV=2)`, `!Name=b`, `!!Name=a`}, Field{Name: "a", IV: 1}, nil},
+		{[]string{`Name=a || Name=b && IV=2`}, Field{Name: "a"}, nil},
+		{[]string{`(Name=a || Name=b) && IV=2`}, Field{Name: "a"}, fmt.Errorf(`Term "IV=2" has value "0" but wants "2"`)},
+		{[]string{`Name=b || IV>1`}, Field{Name: "a", IV: 1}, fmt.Errorf(`Term "Name=b" has value "a" but wants "b" or Term "IV>1" has value "1" but wants > "1"`)},
+		{[]string{`!(Name=a && IV=1)`}, Field{Name: "a", IV: 1}, fmt.Errorf(`Term "!(Name=a && IV=1)" matches`)},
+		// A missing path doesn't match, so its negation does.
+		{[]string{`!Missing=1`}, Field{}, nil},
+		// Operators in values and paths.
+		{[]string{`Name~="^(a|b)$" && Name!=c`, `Name*="a" || Name^=b`, `*/Name=a || {any}/*=b`}, Field{Name: "a"}, fmt.Errorf(`Can't navigate to string "Name" on kind string`)},
+		{[]string{`/!=b`, `/=a`, `{type}=string`}, "a", nil},
+		{[]string{`Name=""`, `Name`}, Field{}, nil},
+		{[]string{``, ` `}, "a", nil},
+		// SYNTAX
+		{[]string{`Name==a`}, Field{}, fmt.Errorf(`expr "Name==a" col 7: contains tokens past the comparison (a)`)},
+		{[]string{`Name=a b`}, Field{}, fmt.Errorf(`col 8: contains tokens past the comparison (b)`)},
+		{[]string{`Name=a &&`}, Field{}, fmt.Errorf(`col 10: is missing a term`)},
+		{[]string{`&& Name=a`}, Field{}, fmt.Errorf(`col 1: is missing a term before "&"`)},
+		{[]string{`(Name=a || IV=1`}, Field{}, fmt.Errorf(`col 1: has unclosed "("`)},
+		{[]string{`Name=a)`}, Field{}, fmt.Errorf(`col 7: has unexpected ")"`)},
+		{[]string{`Name=a & IV=1`}, Field{}, fmt.Errorf(`col 8: has unexpected "&"`)},
+		{[]string{`Name~="("`}, Field{}, fmt.Errorf(`col 7: error parsing regexp`)},
+		{[]string{`Name="a`}, Field{}, fmt.Errorf(`col 6: literal not terminated`)},
+		{[]string{`1.5/Name=a`}, Field{}, fmt.Errorf(`col 1: can't navigate to float "1.5"`)},
+		{[]string{`Name=a`, `{any}/{all}/*=a`}, Field{}, fmt.Errorf(`expr "{any}/{all}/*=a" col 7: has multiple quantifiers`)},
+		{[]string{`Name=1.5~x`}, Field{}, fmt.Errorf(`col 9: has tolerance without a number`)},
+		{[]string{`Name=`}, Field{}, fmt.Errorf(`expr "Name=" col 6: has no value after the comparison`)},
+		{[]string{`[Name=]/IV=1`}, []Field{}, fmt.Errorf(`col 7: has no value after the comparison`)},
+		{[]string{`!=b`}, Field{}, fmt.Errorf(`expr "!=b" col 1: has no path before the comparison`)},
+		{[]string{`IV=1 && =b`}, Field{}, fmt.Errorf(`col 9: has no path before the comparison`)},
+	}
+	for i, v := range table {
+		expr, haveErr := Compile(v.terms...)
+		if haveErr == nil {
+			haveErr = expr.Match(v.dst)
+		}
+		if err := RunErr(haveErr, v.wantErr); err != nil {
+			t.Fatalf("TestCompile %v %v", i, err)
+		} else if haveErr != nil && !strings.Contains(haveErr.Error(), v.wantErr.Error()) {
+			t.Fatalf("TestCompile %v has error \"%v\" but wants \"%v\"", i, haveErr, v.wantErr)
+		}
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-COMPILE-CONCURRENT
+func TestCompileConcurrent(t *testing.T) {
+	expr, err := Compile(`{any}/*/Name~="^b"`, `*/IV>0 && !*/IV>3`, `{count}=3`)
+	if err != nil {
+		t.Fatalf("TestCompileConcurrent has error %v", err)
+	}
+	fields := []Field{{Name: "a", IV: 1}, {Name: "b", IV: 2}, {Name: "c", IV: 3}}
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			dst := fields
+			if i%2 == 1 {
+				dst = fields[:2]
+			}
+			err := expr.Match(dst)
+			if (i%2 == 0) != (err == nil) {
+				errs <- fmt.Errorf("match %v has error %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("TestCompileConcurrent %v", err)
+	}
+}
+
 // ---------------------------------------------------------
 // TEST-RUN
 func TestRun(t *testing.T) {
@@ -62,6 +204,359 @@ func TestRunOpts(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------
+// TEST-JSON
+func TestJSON(t *testing.T) {
+	doc := `{"name": "a", "count": 2, "big": 9007199254740993, "ratio": 0.5, "ok": true, "none": null,
+		"items": [{"kind": "fruit", "name": "apple"}, {"kind": "veg", "name": "kale"}], "empty": {}}`
+	table := []struct {
+		data    string
+		exprs   []string
+		wantErr error
+	}{
+		{doc, []string{`name=a`, `count=2`, `count>1.5`, `ratio=0.5`, `ok=true`, `none/{nil}=true`}, nil},
+		// Numbers are exact.
+		{doc, []string{`big=9007199254740993`, `big>9007199254740992`}, nil},
+		{doc, []string{`{type}=object`, `name/{type}=string`, `count/{type}=number`, `ok/{type}=bool`, `none/{type}=null`, `items/{type}=array`}, nil},
+		{doc, []string{`{count}=8`, `items/{count}=2`, `name/{count}=1`, `empty/{count}=0`, `{keys}="big,count,empty,items,name,none,ok,ratio"`}, nil},
+		{doc, []string{`items/0/name=apple`, `items/*/kind~="^(fruit|veg)$"`, `items/[kind=veg]/name=kale`, `{any}/**/name=kale`}, nil},
+		{`[1, 2, 3]`, []string{`{count}=3`, `*>0`, `2=3`, `{type}=array`}, nil},
+		{`"text"`, []string{`/=text`, `{type}=string`}, nil},
+		// Errors
+		{doc, []string{`count=3`}, fmt.Errorf(`Term "count=3" has value "2" but wants "3"`)},
+		{doc, []string{`nmae=a`}, fmt.Errorf(`no key nmae on map[string]interface {}; did you mean name`)},
+		{doc, []string{`items/2/name=a`}, fmt.Errorf(`Index 2 is out of range on slice with len 2`)},
+		{`{"a": 1`, []string{`a=1`}, fmt.Errorf(`jacl: can't decode JSON`)},
+		{`{"a": 1} {}`, []string{`a=1`}, fmt.Errorf(`jacl: can't decode JSON: data past the document`)},
+	}
+	for i, v := range table {
+		haveErr := RunJSON([]byte(v.data), v.exprs...)
+		if err := RunErr(haveErr, v.wantErr); err != nil {
+			t.Fatalf("TestJSON %v %v", i, err)
+		} else if haveErr != nil && !strings.Contains(haveErr.Error(), v.wantErr.Error()) {
+			t.Fatalf("TestJSON %v has error \"%v\" but wants \"%v\"", i, haveErr, v.wantErr)
+		}
+	}
+	// Trees
+	tree := testTree{"a": []any{1.0, "b"}}
+	if err := RunTree(tree, `a/{type}=array`, `a/0=1`, `a/0/{type}=number`, `a/1=b`); err != nil {
+		t.Fatalf("TestJSON tree has error %v", err)
+	}
+	if err := RunTree(map[string]any{"a": nil}, `a/{type}=null`); err != nil {
+		t.Fatalf("TestJSON tree has error %v", err)
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-NAVIGATE
+func TestNavigate(t *testing.T) {
+	var nilField *Field
+	var nilAny any
+	nav := Nav{
+		Embedded: Embedded{ID: "e1"},
+		Arr:      [3]int{1, 2, 3},
+		ByInt:    map[int]string{2: "two", 10: "ten", 1: "one"},
+		ByKey:    map[Key]Field{"k": {Name: "kn"}},
+		ByUint:   map[uint8]bool{7: true},
+		Any:      Field{Name: "anyname"},
+		List:     List{"a", "b"},
+		Dur:      1500 * time.Millisecond,
+	}
+	table := []struct {
+		dst     any
+		exprs   []string
+		wantErr error
+	}{
+		// ARRAYS
+		{nav, []string{`Arr/0=1`, `Arr/2=3`, `Arr/{count}=3`, `Arr/*>0`}, nil},
+		{[2]Field{{Name: "a"}, {Name: "b"}}, []string{`1/Name=b`, `{any}/*/Name=a`}, nil},
+		{nav, []string{`Arr/3=1`}, fmt.Errorf(`Index 3 is out of range on array with len 3`)},
+		// EMBEDDED
+		{nav, []string{`ID=e1`, `Embedded/ID=e1`}, nil},
+		{&nav, []string{`ID=e1`, `Value=0`}, fmt.Errorf(`Can't navigate to field "Value" on jacl.Nav: reflect: indirection through nil pointer to embedded struct field Inner`)},
+		{Nav{Inner: &Inner{Value: 5}}, []string{`Value=5`, `Inner/Value=5`}, nil},
+		// MAPS
+		{nav, []string{`ByInt/2=two`, `ByInt/10=ten`, `ByKey/k/Name=kn`, `ByUint/7=true`, `{any}/ByInt/*=one`}, nil},
+		{nav, []string{`ByInt/3=two`}, fmt.Errorf(`no key 3 on map[int]string`)},
+		{nav, []string{`ByInt/x=two`}, fmt.Errorf(`Can't use key "x" on map with int keys`)},
+		{nav, []string{`ByUint/300=true`}, fmt.Errorf(`Can't use key "300" on map with uint8 keys`)},
+		{map[string]int{"0": 1}, []string{`0=1`}, nil},
+		// INTERFACES AND POINTERS
+		{nav, []string{`Any/Name=anyname`, `Any/{type}=Field`}, nil},
+		{[]any{&Field{Name: "a"}, nil}, []string{`0/Name=a`, `1/{nil}=true`, `1/{type}=nil`, `0/{nil}=false`}, nil},
+		{&[]int{4, 5}, []string{`1=5`, `{count}=2`}, nil},
+		{nilField, []string{`{nil}=true`, `{type}="*Field"`}, nil},
+		{nilAny, []string{`{nil}=true`, `{type}=nil`}, nil},
+		{nilField, []string{`Name=a`}, fmt.Errorf(`Can't navigate to string "Name" on nil *jacl.Field`)},
+		{nilField, []string{`0=a`}, fmt.Errorf(`Can't navigate to int "0" on nil *jacl.Field`)},
+		{nilAny, []string{`Name=a`}, fmt.Errorf(`Can't navigate to string "Name" on nil`)},
+		{nav, []string{`ByInt/{nil}=false`, `Arr/{nil}=false`, `Missing/{nil}=true`}, fmt.Errorf(`no field Missing`)},
+		// METHODS
+		{nav, []string{`List/Len=2`, `List/First=a`, `List/Checked=a`, `Dur/String="1.5s"`, `Dur/Seconds=1.5`, `PtrMethod=ptr`}, nil},
+		{&nav, []string{`PtrMethod=ptr`, `Dur/Seconds>1`}, nil},
+		{nav, []string{`List/Fails=a`}, fmt.Errorf(`method "Fails" on jacl.List: failed`)},
+		{nav, []string{`List/At=a`}, fmt.Errorf(`Can't call method "At" on jacl.List`)},
+		{nav, []string{`List/Missing=a`}, fmt.Errorf(`Can't navigate to string "Missing" on kind slice`)},
+		// Map keys take precedence over methods.
+		{Methods{"Len": 10}, []string{`Len=10`, `Size=1`}, nil},
+		// KEYS
+		{nav, []string{`ByInt/{keys}="1,2,10"`, `ByKey/{keys}=k`, `{keys}=a`}, fmt.Errorf(`{keys} on invalid type jacl.Nav`)},
+		{map1, []string{`{keys}="a,b,cf,ct"`}, nil},
+		{map[string]int{}, []string{`{keys}=""`}, nil},
+	}
+	for i, v := range table {
+		haveErr := Run(v.dst, v.exprs...)
+		if err := RunErr(haveErr, v.wantErr); err != nil {
+			t.Fatalf("TestNavigate %v %v", i, err)
+		} else if haveErr != nil && !strings.Contains(haveErr.Error(), v.wantErr.Error()) {
+			t.Fatalf("TestNavigate %v has error \"%v\" but wants \"%v\"", i, haveErr, v.wantErr)
+		}
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-REPORT
+func TestReport(t *testing.T) {
+	fields := []Field{{Name: "a", IV: 1}, {Name: "b", IV: 2}}
+	table := []struct {
+		dst   any
+		terms []string
+		want  []string
+	}{
+		{fields, []string{`0/Name=a`, `{count}=2`}, nil},
+		// Every term is evaluated.
+		{fields, []string{`0/Name=b`, `{count}=2`, `1/IV>2`},
+			[]string{`Term "0/Name=b" at 0/Name: has "a", wants "b"`, `Term "1/IV>2" at 1/IV: has 2, wants > "2"`}},
+		// Every failing value is reported.
+		{fields, []string{`*/IV<2`, `*/Name^=x`},
+			[]string{`Term "*/IV<2" at 1/IV: has 2, wants < "2"`, `Term "*/Name^=x" at 0/Name: has "a", wants ^= "x"`, `Term "*/Name^=x" at 1/Name: has "b", wants ^= "x"`}},
+		{fields, []string{`{none}/*/IV>0`, `{any}/*/Name=c`, `{}/Name=a`},
+			[]string{`Term "{none}/*/IV>0" at 0/IV: has 1, matches`, `Term "{none}/*/IV>0" at 1/IV: has 2, matches`,
+				`Term "{any}/*/Name=c" at 0/Name: has "a", wants "c"`, `Term "{any}/*/Name=c" at 1/Name: has "b", wants "c"`,
+				`Term "{}/Name=a" at {}: Can't navigate to string "{}" on kind slice`}},
+		{Field{Name: "a"}, []string{`/=x`, `{all}/*/Missing`, `**/Missing=1`},
+			[]string{`Term "/=x" at /: has jacl.Field{Name:"a", IV:0, SV:"", BV:false}, wants "x"`,
+				`Term "{all}/*/Missing" at Name/Missing: Can't navigate to string "Missing" on kind string`,
+				`Term "{all}/*/Missing" at IV/Missing: Can't navigate to string "Missing" on kind int`,
+				`Term "{all}/*/Missing" at SV/Missing: Can't navigate to string "Missing" on kind string`,
+				`Term "{all}/*/Missing" at BV/Missing: Can't navigate to string "Missing" on kind bool`,
+				`Term "**/Missing=1": has no values`}},
+		// Suggestions
+		{Field{Name: "a"}, []string{`Nmae=a`, `name=a`, `Bv=true`, `Nope=a`},
+			[]string{`Term "Nmae=a" at Nmae: no field Nmae on jacl.Field; did you mean Name`,
+				`Term "name=a" at name: no field name on jacl.Field; did you mean Name`,
+				`Term "Bv=true" at Bv: no field Bv on jacl.Field; did you mean BV`,
+				`Term "Nope=a" at Nope: no field Nope on jacl.Field`}},
+		{map1, []string{`ctt/BV=true`, `d/BV=true`},
+			[]string{`Term "ctt/BV=true" at ctt: no key ctt on map[string]jacl.Field; did you mean ct`,
+				`Term "d/BV=true" at d: no key d on map[string]jacl.Field`}},
+		// Boolean composition
+		{Field{Name: "a", IV: 1}, []string{`Name=b || IV=2`, `Name=a && IV=2`, `!Name=a`, `Name=b || IV=1`},
+			[]string{`Term "Name=b" at Name: has "a", wants "b"`, `Term "IV=2" at IV: has 1, wants "2"`,
+				`Term "IV=2" at IV: has 1, wants "2"`, `Term "!Name=a": matches`}},
+	}
+	for i, v := range table {
+		err := RunReport(v.dst, v.terms...)
+		if len(v.want) < 1 {
+			if err != nil {
+				t.Fatalf("TestReport %v expected no error but has %v", i, err)
+			}
+			continue
+		}
+		rep, ok := err.(*Report)
+		if !ok {
+			t.Fatalf("TestReport %v has error %v but wants a report", i, err)
+		}
+		have := strings.Split(rep.Error(), "\n")
+		if len(have) != len(rep.Failures) || strings.Join(have, "\n") != strings.Join(v.want, "\n") {
+			t.Fatalf("TestReport %v has\n%v\nbut wants\n%v", i, rep, strings.Join(v.want, "\n"))
+		}
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-SELECT
+func TestSelect(t *testing.T) {
+	items := Items{Items: []Item{{Kind: "fruit", Name: "apple", Count: 2}, {Kind: "veg", Name: "kale", Count: 1}, {Kind: "fruit", Name: "fig"}}}
+	table := []struct {
+		dst     any
+		path    string
+		want    []any
+		wantErr error
+	}{
+		{items, `Items/0/Name`, []any{"apple"}, nil},
+		{items, `Items/*/Name`, []any{"apple", "kale", "fig"}, nil},
+		{items, `Items/*/Count`, []any{2, 1, 0}, nil},
+		{items, ``, []any{items}, nil},
+		{map1, `*/Name`, []any{"blip", "bop", ":(", ":)"}, nil},
+		// Values that can't navigate past a wildcard are skipped.
+		{[]any{map[string]any{"a": 1}, map[string]any{}, map[string]any{"a": 3}}, `*/a`, []any{1, 3}, nil},
+		{map2, `**/Name`, []any{"found", "dash"}, nil},
+		// PREDICATES
+		{items, `Items/[Kind=fruit]/Name`, []any{"apple", "fig"}, nil},
+		{items, `Items/[Kind=fruit && Count>0]/Name`, []any{"apple"}, nil},
+		{items, `Items/[Kind=veg || Name^=f]/Name`, []any{"kale", "fig"}, nil},
+		{items, `Items/[!Kind=fruit]/Name`, []any{"kale"}, nil},
+		{items, `Items/[Kind=meat]/Name`, []any{}, nil},
+		{items, `Items/[Missing=1]/Name`, []any{}, nil},
+		{map1, `[BV=true]/Name`, []any{":)"}, nil},
+		{[][]int{{1, 2}, {3}}, `[{count}=2]/*`, []any{1, 2}, nil},
+		// Errors
+		{items, `Items/5/Name`, nil, fmt.Errorf(`Index 5 is out of range`)},
+		{items, `Items/0/Name=apple`, nil, fmt.Errorf(`expr "Items/0/Name=apple" is not a path`)},
+		{items, `Items/0 || Items/1`, nil, fmt.Errorf(`is not a path`)},
+		{items, `Items/[Kind=fruit/Name`, nil, fmt.Errorf(`expr "Items/[Kind=fruit/Name" col 18: contains tokens past the comparison (/)`)},
+		{items, `Items/[Kind=fruit`, nil, fmt.Errorf(`col 7: has unclosed "["`)},
+		{items, `Items/[]/Name`, nil, fmt.Errorf(`col 8: is missing a term before "]"`)},
+	}
+	for i, v := range table {
+		have, haveErr := Select(v.dst, v.path)
+		if err := RunErr(haveErr, v.wantErr); err != nil {
+			t.Fatalf("TestSelect %v %v", i, err)
+		} else if haveErr != nil {
+			if !strings.Contains(haveErr.Error(), v.wantErr.Error()) {
+				t.Fatalf("TestSelect %v has error \"%v\" but wants \"%v\"", i, haveErr, v.wantErr)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(have, v.want) {
+			t.Fatalf("TestSelect %v has %#v but wants %#v", i, have, v.want)
+		}
+	}
+	// Predicates work in terms.
+	if err := Run(items, `Items/[Kind=fruit]/Name$=e || Items/[Kind=fruit]/Count<3`, `{none}/Items/[Kind=veg]/Count>1`); err != nil {
+		t.Fatalf("TestSelect has error %v", err)
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-GET
+func TestGet(t *testing.T) {
+	items := Items{Items: []Item{{Kind: "fruit", Name: "apple", Count: 2}}}
+	if have, err := Get[string](items, `Items/0/Name`); err != nil || have != "apple" {
+		t.Fatalf("TestGet has %v %v but wants apple", have, err)
+	}
+	if have, err := Get[float64](items, `Items/0/Count`); err != nil || have != 2 {
+		t.Fatalf("TestGet has %v %v but wants 2", have, err)
+	}
+	if have, err := Get[Item](items, `Items/[Name=apple]`); err != nil || have.Kind != "fruit" {
+		t.Fatalf("TestGet has %v %v but wants fruit", have, err)
+	}
+	if have, err := Get[string](map[string]Key{"k": "v"}, `k`); err != nil || have != "v" {
+		t.Fatalf("TestGet has %v %v but wants v", have, err)
+	}
+	if have, err := Get[*Field](map[string]any{"f": nil}, `f`); err != nil || have != nil {
+		t.Fatalf("TestGet has %v %v but wants nil", have, err)
+	}
+	if have, err := Get[int](map1, `b/{count}`); err == nil {
+		t.Fatalf("TestGet has %v but wants an error", have)
+	}
+	if have, err := Get[int](map[string]any{"f": 2.0}, `f`); err != nil || have != 2 {
+		t.Fatalf("TestGet has %v %v but wants 2", have, err)
+	}
+	if have, err := Get[int](map[string]any{"f": 1.5}, `f`); err == nil {
+		t.Fatalf("TestGet has %v but wants an error", have)
+	}
+	if have, err := Get[uint8](map[string]any{"f": float32(-0.5)}, `f`); err == nil {
+		t.Fatalf("TestGet has %v but wants an error", have)
+	}
+	if have, err := Get[int](items, `Items/0/Name`); err == nil {
+		t.Fatalf("TestGet has %v but wants an error", have)
+	}
+	if have, err := Get[string](items, `Items/*/Count`); err == nil {
+		t.Fatalf("TestGet has %v but wants an error", have)
+	}
+	if have, err := Get[string](items, `Items/[Kind=veg]/Name`); err == nil {
+		t.Fatalf("TestGet has %v but wants an error", have)
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-T
+func TestT(t *testing.T) {
+	table := []struct {
+		dst       any
+		terms     []string
+		wantError string
+		wantFatal string
+	}{
+		{Field{Name: "a"}, []string{`Name=a`}, "", ""},
+		{Field{Name: "a"}, []string{`Name=b`, `IV=1`}, "Term \"Name=b\" at Name: has \"a\", wants \"b\"\nTerm \"IV=1\" at IV: has 0, wants \"1\"", ""},
+		{Field{Name: "a"}, []string{`Name`, `(`}, "", `expr "(" col 2: is missing a term`},
+	}
+	for i, v := range table {
+		tb := &recordTB{TB: t}
+		func() {
+			// Fatal exits the goroutine in a real test; here it panics.
+			defer func() { recover() }()
+			T(tb, v.dst, v.terms...)
+		}()
+		if !tb.helper {
+			t.Fatalf("TestT %v didn't call Helper", i)
+		} else if tb.err != v.wantError {
+			t.Fatalf("TestT %v has error \"%v\" but wants \"%v\"", i, tb.err, v.wantError)
+		} else if tb.fatal != v.wantFatal {
+			t.Fatalf("TestT %v has fatal \"%v\" but wants \"%v\"", i, tb.fatal, v.wantFatal)
+		}
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-WILDCARD
+func TestWildcard(t *testing.T) {
+	fields := []Field{{Name: "a", IV: 1, BV: true}, {Name: "b", IV: 2, BV: true}, {Name: "c", IV: 3}}
+	nested := map[string]any{
+		"a": []any{map[string]any{"Enabled": true}, map[string]any{"Enabled": true, "b": map[string]any{"Enabled": true}}},
+		"c": &Field{Name: "x", BV: true},
+	}
+	table := []struct {
+		dst   any
+		exprs []string
+		// wantErr is contained in the error, to verify the reported paths.
+		wantErr error
+	}{
+		// ALL
+		{fields, []string{`*/IV>0`, `{all}/*/IV<4`, `*/Name~="^[a-c]$"`, `{count}=3`}, nil},
+		{fields, []string{`*/BV=true`}, fmt.Errorf(`Term "*/BV=true" fails at 2/BV has value "false" but wants "true"`)},
+		{fields, []string{`*/IV<2`}, fmt.Errorf(`1/IV has value "2" but wants < "2"; 2/IV has value "3"`)},
+		{map1, []string{`*/Name!=""`}, nil},
+		{map1, []string{`*/IV=10`}, fmt.Errorf(`fails at a/IV has value "0" but wants "10"; cf/IV`)},
+		{Field{Name: "a", SV: "a"}, []string{`{all}/*=a`}, fmt.Errorf(`fails at IV can't compare int 0 to "a"`)},
+		{[]Field{}, []string{`*/Name=a`}, fmt.Errorf(`Term "*/Name=a" has no values`)},
+		// Values that can't navigate the path fail.
+		{[]any{map[string]any{"a": 1}, map[string]any{}}, []string{`*/a=1`}, fmt.Errorf(`1/a no key a on map[string]interface {}`)},
+		{[]*Field{{Name: "a"}, nil}, []string{`*/Name=a`}, fmt.Errorf(`1/Name Can't navigate to string "Name" on nil`)},
+		// ANY
+		{fields, []string{`{any}/*/Name=b`, `*/{any}/BV=false`, `{any}/*/IV>=3`}, nil},
+		{fields, []string{`{any}/*/Name=d`}, fmt.Errorf(`Term "{any}/*/Name=d" has no match: 0/Name has value "a" but wants "d"`)},
+		{[]Field{}, []string{`{any}/*/Name=a`}, fmt.Errorf(`has no values`)},
+		// NONE
+		{fields, []string{`{none}/*/Name=d`, `{none}/*/IV>3`, `{none}/Name`, `{none}/*/Missing`}, nil},
+		{fields, []string{`{none}/*/IV>1`}, fmt.Errorf(`Term "{none}/*/IV>1" matches at 1/IV, 2/IV`)},
+		{[]Field{}, []string{`{none}/*/Name=a`}, nil},
+		// Quantifiers without wildcards
+		{Field{Name: "a"}, []string{`{none}/Name=b`, `{any}/Name=a`}, nil},
+		// DEEP
+		{nested, []string{`**/Enabled=true`, `**/BV=true`, `{any}/**/Name=x`, `{none}/**/Name=y`, `a/**/Enabled=true`}, nil},
+		{nested, []string{`**/{count}>1`}, fmt.Errorf(`a/1/b/{count} has value "1" but wants > "1"`)},
+		{fields, []string{`**/IV>1`}, fmt.Errorf(`Term "**/IV>1" fails at 0/IV has value "1" but wants > "1"`)},
+		{fields, []string{`**/Missing=1`}, fmt.Errorf(`has no values`)},
+		// Multiple wildcards
+		{map2, []string{`*/*/Name$=d`, `{any}/*/*/Name=found`}, fmt.Errorf(`a/b/Name has value "dash" but wants $= "d"`)},
+		// Errors
+		{fields, []string{`{any}/{all}/*/Name=a`}, fmt.Errorf(`has multiple quantifiers`)},
+		{fields, []string{`0/Name/*=a`}, fmt.Errorf(`0/Name/* Can't navigate to "*" on kind string`)},
+	}
+	for i, v := range table {
+		haveErr := Run(v.dst, v.exprs...)
+		if err := RunErr(haveErr, v.wantErr); err != nil {
+			t.Fatalf("TestWildcard %v %v", i, err)
+		} else if haveErr != nil && !strings.Contains(haveErr.Error(), v.wantErr.Error()) {
+			t.Fatalf("TestWildcard %v has error \"%v\" but wants \"%v\"", i, haveErr, v.wantErr)
+		}
+	}
+}
+
 // ---------------------------------------------------------
 // SUPPORT
 
@@ -72,6 +567,128 @@ type Field struct {
 	BV   bool
 }
 
+// recordTB records the failures of a test.
+type recordTB struct {
+	testing.TB
+	helper bool
+	err    string
+	fatal  string
+}
+
+func (r *recordTB) Helper() {
+	r.helper = true
+}
+
+func (r *recordTB) Error(args ...any) {
+	r.err = fmt.Sprint(args...)
+}
+
+func (r *recordTB) Fatal(args ...any) {
+	r.fatal = fmt.Sprint(args...)
+	panic(r.fatal)
+}
+
+type Nav struct {
+	Embedded
+	*Inner
+	Arr    [3]int
+	ByInt  map[int]string
+	ByKey  map[Key]Field
+	ByUint map[uint8]bool
+	Any    any
+	List   List
+	Dur    time.Duration
+}
+
+func (n *Nav) PtrMethod() string {
+	return "ptr"
+}
+
+type Embedded struct {
+	ID string
+}
+
+type Inner struct {
+	Value int
+}
+
+type Items struct {
+	Items []Item
+}
+
+type Item struct {
+	Kind  string
+	Name  string
+	Count int
+}
+
+type Key string
+
+// testTree is a Tree.
+type testTree map[string]any
+
+func (t testTree) Tree() any {
+	return map[string]any(t)
+}
+
+type List []string
+
+func (l List) Len() int {
+	return len(l)
+}
+
+func (l List) First() string {
+	return l[0]
+}
+
+func (l List) Checked() (string, error) {
+	return l[0], nil
+}
+
+func (l List) Fails() (string, error) {
+	return "", fmt.Errorf("failed")
+}
+
+func (l List) At(i int) string {
+	return l[i]
+}
+
+type Methods map[string]int
+
+func (m Methods) Len() int {
+	return len(m)
+}
+
+func (m Methods) Size() int {
+	return len(m)
+}
+
+type Numbers struct {
+	I8   int8
+	U8   uint8
+	U64  uint64
+	F32  float32
+	F64  float64
+	Flag flag
+}
+
+type flag uint8
+
+const (
+	flagA flag = 1 << iota
+	flagB
+)
+
+func (f flag) String() string {
+	switch f {
+	case flagA:
+		return "a"
+	case flagB:
+		return "b"
+	}
+	return strconv.Itoa(int(f))
+}
+
 var (
 	map1 = map[string]Field{
 		"a":  {Name: "blip", SV: "bloop"},
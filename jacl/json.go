@@ -0,0 +1,82 @@
+package jacl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Tree is implemented by values that answer their contents as a
+// tree of map[string]any, []any and scalars, such as cfg.Settings.
+type Tree interface {
+	Tree() any
+}
+
+// RunJSON compares a list of terms against a JSON document.
+// See Run docs for a description of terms. "{type}" answers
+// the JSON kind of a value: object, array, string, number,
+// bool or null.
+func RunJSON(data []byte, terms ...string) error {
+	return RunJSONOpts(Opts{}, data, terms...)
+}
+
+// RunJSONOpts is RunJSON with options, see Opts docs.
+func RunJSONOpts(opts Opts, data []byte, terms ...string) error {
+	target, err := decodeJSON(data)
+	if err != nil {
+		return err
+	}
+	opts.JSONTypes = true
+	return RunOpts(opts, target, terms...)
+}
+
+// RunTree compares a list of terms against a tree of
+// map[string]any, []any and scalars, as decoded by encoding/json,
+// or a Tree. "{type}" answers the JSON kind of a value, see RunJSON.
+func RunTree(tree any, terms ...string) error {
+	return RunTreeOpts(Opts{}, tree, terms...)
+}
+
+// RunTreeOpts is RunTree with options, see Opts docs.
+func RunTreeOpts(opts Opts, tree any, terms ...string) error {
+	if t, ok := tree.(Tree); ok {
+		tree = t.Tree()
+	}
+	opts.JSONTypes = true
+	return RunOpts(opts, tree, terms...)
+}
+
+// decodeJSON answers the document as a tree, keeping
+// numbers exact.
+func decodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var target any
+	if err := dec.Decode(&target); err != nil {
+		return nil, fmt.Errorf("jacl: can't decode JSON: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("jacl: can't decode JSON: data past the document")
+	}
+	return target, nil
+}
+
+// getJSONTypeName answers the JSON kind of a.
+func getJSONTypeName(a any) string {
+	switch a.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case json.Number, float64, float32, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return "number"
+	}
+	return getTypeName(a)
+}
@@ -11,6 +11,18 @@ type Opts struct {
 	// * Two single quotes ('') are replaced with a double quote (").
 	// Default is false.
 	RawValues bool
+
+	// Tolerance is the default allowed difference when comparing
+	// floats with "=" and "!=". Terms can supply their own
+	// tolerance, see Run docs.
+	// Default is 0, i.e. floats must be equal.
+	Tolerance float64
+
+	// If JSONTypes is true then "{type}" answers the JSON
+	// kind of values: object, array, string, number, bool
+	// or null. RunJSON and RunTree set it.
+	// Default is false.
+	JSONTypes bool
 }
 
 func (o Opts) processValue(s string) string {
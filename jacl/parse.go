@@ -0,0 +1,521 @@
+package jacl
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/scanner"
+	"unicode"
+)
+
+// Expr is a list of compiled terms. It is immutable, and
+// safe for concurrent use.
+type Expr struct {
+	opts  Opts
+	terms []matcher
+}
+
+// Compile parses a list of terms into an expression that can
+// be matched against many targets. See Run docs for a description
+// of terms. In addition, a term can combine comparisons with
+// "&&", "||", "!" and parentheses, i.e.
+//
+//	"Name=a || (Name=b && !IV<2)"
+//
+// Syntax errors include the column of the error in the term.
+func Compile(terms ...string) (*Expr, error) {
+	return CompileOpts(Opts{}, terms...)
+}
+
+// CompileOpts parses a list of terms into an expression.
+// See Compile docs for a description.
+func CompileOpts(opts Opts, terms ...string) (*Expr, error) {
+	e := &Expr{opts: opts}
+	for _, term := range terms {
+		p := &parser{opts: opts, src: term}
+		m, err := p.parse()
+		if err != nil {
+			return nil, err
+		}
+		e.terms = append(e.terms, m)
+	}
+	return e, nil
+}
+
+// Match compares the target against every term,
+// returning the first failure.
+func (e *Expr) Match(target any) error {
+	for _, m := range e.terms {
+		if err := m.match(e.opts, target, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matcher is a node in a compiled expression. If rep is
+// not nil, every failure is added to it.
+type matcher interface {
+	match(opts Opts, target any, rep *Report) error
+}
+
+// andMatcher only evaluates b if a succeeds, unless reporting.
+type andMatcher struct {
+	a, b matcher
+}
+
+func (m *andMatcher) match(opts Opts, target any, rep *Report) error {
+	erra := m.a.match(opts, target, rep)
+	if erra != nil && rep == nil {
+		return erra
+	}
+	errb := m.b.match(opts, target, rep)
+	if erra != nil {
+		return erra
+	}
+	return errb
+}
+
+type orMatcher struct {
+	a, b matcher
+}
+
+func (m *orMatcher) match(opts Opts, target any, rep *Report) error {
+	var repa, repb *Report
+	if rep != nil {
+		repa, repb = &Report{}, &Report{}
+	}
+	erra := m.a.match(opts, target, repa)
+	if erra == nil {
+		return nil
+	}
+	errb := m.b.match(opts, target, repb)
+	if errb == nil {
+		return nil
+	}
+	if rep != nil {
+		rep.Failures = append(rep.Failures, repa.Failures...)
+		rep.Failures = append(rep.Failures, repb.Failures...)
+	}
+	return fmt.Errorf("%w or %w", erra, errb)
+}
+
+// notMatcher succeeds if its matcher fails for any
+// reason, including a path that doesn't exist.
+type notMatcher struct {
+	m    matcher
+	text string
+}
+
+func (m *notMatcher) match(opts Opts, target any, rep *Report) error {
+	if err := m.m.match(opts, target, nil); err != nil {
+		return nil
+	}
+	rep.add(Failure{Term: m.text, Message: "matches"})
+	return fmt.Errorf("Term \"%v\" matches", m.text)
+}
+
+// term is a single path and comparison.
+type term struct {
+	text       string
+	segments   []segment
+	quantifier quantifier
+	// op is empty if the term only requires the path to exist.
+	op   compareOp
+	want compareValue
+}
+
+func (t *term) match(opts Opts, target any, rep *Report) error {
+	r := &runner{opts: opts, nodes: []node{{value: target}}, quantifier: t.quantifier, currentTerm: t.text}
+	err := r.run(t)
+	if err != nil {
+		rep.add(r.failures...)
+	}
+	return err
+}
+
+type segmentKind int
+
+const (
+	fieldSegment        segmentKind = iota // A struct field, map key or keyword
+	indexSegment                           // A slice index
+	wildcardSegment                        // "*"
+	deepWildcardSegment                    // "**"
+	predicateSegment                       // "[Kind=fruit]"
+)
+
+// segment is a single step in a path.
+type segment struct {
+	kind segmentKind
+	name string
+	// index is only valid for indexSegment.
+	index int
+	// pred is only valid for predicateSegment.
+	pred matcher
+}
+
+// ---------------------------------------------------------
+// PARSER
+
+// token is a scanned token, along with its position in
+// the source, so operators can be assembled from adjacent
+// characters.
+type token struct {
+	tok    rune
+	text   string
+	offset int
+	col    int
+}
+
+// end answers the offset just past the token.
+func (t token) end() int {
+	return t.offset + len(t.text)
+}
+
+// parser builds a matcher from a term using the grammar
+//
+//	or      = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | "(" or ")" | term
+//	term    = path [ operator value ]
+//	path    = segment { "/" segment }
+//	segment = name | index | "*" | "**" | "[" or "]"
+type parser struct {
+	opts   Opts
+	src    string
+	tokens []token
+	pos    int
+}
+
+func (p *parser) parse() (matcher, error) {
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	m, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := p.peek(0); ok {
+		return nil, p.errorAt(t.col, "has unexpected \"%v\"", t.text)
+	}
+	return m, nil
+}
+
+func (p *parser) lex() error {
+	var scan scanner.Scanner
+	var err error
+	scan.Init(strings.NewReader(p.src))
+	scan.Mode = scanner.ScanChars | scanner.ScanFloats | scanner.ScanIdents | scanner.ScanInts | scanner.ScanRawStrings | scanner.ScanStrings
+	scan.IsIdentRune = isIdentRune
+	scan.Error = func(s *scanner.Scanner, msg string) {
+		pos := s.Position
+		if !pos.IsValid() {
+			pos = s.Pos()
+		}
+		if err == nil {
+			err = p.errorAt(pos.Column, "%v", msg)
+		}
+	}
+	for tok := scan.Scan(); tok != scanner.EOF; tok = scan.Scan() {
+		p.tokens = append(p.tokens, token{tok: tok, text: scan.TokenText(), offset: scan.Position.Offset, col: scan.Position.Column})
+	}
+	return err
+}
+
+func (p *parser) parseOr() (matcher, error) {
+	m, err := p.parseAnd()
+	for err == nil && p.acceptPair('|') {
+		var b matcher
+		b, err = p.parseAnd()
+		m = &orMatcher{a: m, b: b}
+	}
+	return m, err
+}
+
+func (p *parser) parseAnd() (matcher, error) {
+	m, err := p.parseUnary()
+	for err == nil && p.acceptPair('&') {
+		var b matcher
+		b, err = p.parseUnary()
+		m = &andMatcher{a: m, b: b}
+	}
+	return m, err
+}
+
+func (p *parser) parseUnary() (matcher, error) {
+	t, ok := p.peek(0)
+	if !ok {
+		if len(p.tokens) > 0 {
+			return nil, p.errorAt(p.endCol(), "is missing a term")
+		}
+		// An empty term always matches.
+		return &term{}, nil
+	}
+	switch {
+	case t.tok == '!' && !p.adjacent(0, '='):
+		p.pos++
+		m, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notMatcher{m: m, text: p.textFrom(t)}, nil
+	case t.tok == '(':
+		p.pos++
+		m, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if end, ok := p.peek(0); !ok || end.tok != ')' {
+			return nil, p.errorAt(t.col, "has unclosed \"(\"")
+		}
+		p.pos++
+		return m, nil
+	case isTermEnd(t):
+		return nil, p.errorAt(t.col, "is missing a term before \"%v\"", t.text)
+	}
+	return p.parseTerm()
+}
+
+func (p *parser) parseTerm() (matcher, error) {
+	first, _ := p.peek(0)
+	t := &term{}
+	// segmentStart is true when the next token begins
+	// a path segment, which is where a "*" is a wildcard
+	// and not the start of an operator.
+	segmentStart := true
+	for {
+		tok, ok := p.peek(0)
+		if !ok || isTermEnd(tok) {
+			break
+		}
+		if tok.tok == '*' && segmentStart {
+			if p.adjacent(0, '*') {
+				p.pos += 2
+				t.segments = append(t.segments, segment{kind: deepWildcardSegment, name: "**"})
+			} else {
+				p.pos++
+				t.segments = append(t.segments, segment{kind: wildcardSegment, name: "*"})
+			}
+			segmentStart = false
+			continue
+		}
+		if tok.tok == '[' && segmentStart {
+			seg, err := p.parsePredicate()
+			if err != nil {
+				return nil, err
+			}
+			t.segments = append(t.segments, seg)
+			segmentStart = false
+			continue
+		}
+		if op, ok := p.acceptOp(); ok {
+			if tok == first {
+				return nil, p.errorAt(tok.col, "has no path before the comparison")
+			}
+			valueCol := p.endCol()
+			if vt, ok := p.peek(0); ok {
+				valueCol = vt.col
+			}
+			want, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			if op == opMatch {
+				want.re, err = regexp.Compile(p.opts.processValue(want.text))
+				if err != nil {
+					return nil, p.errorAt(valueCol, "%v", err)
+				}
+			}
+			t.op, t.want = op, want
+			break
+		}
+		p.pos++
+		segmentStart = tok.tok == '/'
+		switch tok.tok {
+		case '/':
+			// Path separator, continue
+		case scanner.Int:
+			i, err := strconv.Atoi(tok.text)
+			if err != nil {
+				return nil, p.errorAt(tok.col, "%v", err)
+			}
+			t.segments = append(t.segments, segment{kind: indexSegment, name: tok.text, index: i})
+		case scanner.String:
+			name := strings.Trim(tok.text, `"`)
+			t.segments = append(t.segments, segment{kind: fieldSegment, name: name})
+		case scanner.Ident:
+			if q, ok := quantifiers[tok.text]; ok {
+				if t.quantifier != noQuantifier {
+					return nil, p.errorAt(tok.col, "has multiple quantifiers")
+				}
+				t.quantifier = q
+				continue
+			}
+			t.segments = append(t.segments, segment{kind: fieldSegment, name: tok.text})
+		case scanner.Float:
+			return nil, p.errorAt(tok.col, "can't navigate to float \"%v\"", tok.text)
+		default:
+			return nil, p.errorAt(tok.col, "can't navigate to \"%v\"", tok.text)
+		}
+	}
+	t.text = p.textFrom(first)
+	return t, nil
+}
+
+// parsePredicate answers a segment that selects the children
+// that match the expression in brackets, i.e. "[Kind=fruit]".
+func (p *parser) parsePredicate() (segment, error) {
+	start, _ := p.peek(0)
+	p.pos++
+	m, err := p.parseOr()
+	if err != nil {
+		return segment{}, err
+	}
+	if end, ok := p.peek(0); !ok || end.tok != ']' {
+		return segment{}, p.errorAt(start.col, "has unclosed \"[\"")
+	}
+	p.pos++
+	return segment{kind: predicateSegment, name: p.textFrom(start), pred: m}, nil
+}
+
+// acceptOp consumes the comparison operator at the
+// current token, if there is one.
+func (p *parser) acceptOp() (compareOp, bool) {
+	t, _ := p.peek(0)
+	switch t.tok {
+	case '=':
+		p.pos++
+		return opEq, true
+	case '<', '>':
+		if p.adjacent(0, '=') {
+			p.pos += 2
+			return compareOp(t.text + "="), true
+		}
+		p.pos++
+		return compareOp(t.text), true
+	case '!', '~', '^', '$', '*':
+		if p.adjacent(0, '=') {
+			p.pos += 2
+			return compareOp(t.text + "="), true
+		}
+	}
+	return "", false
+}
+
+// parseValue answers the value that follows an operator,
+// which is a single token, a signed number, or a number
+// with a tolerance ("1.5~0.01"). A missing value is an error.
+func (p *parser) parseValue() (compareValue, error) {
+	var tokens []token
+	for t, ok := p.peek(0); ok && !isTermEnd(t); t, ok = p.peek(0) {
+		tokens = append(tokens, t)
+		p.pos++
+	}
+	v := compareValue{}
+	if len(tokens) < 1 {
+		col := p.endCol()
+		if t, ok := p.peek(0); ok {
+			col = t.col
+		}
+		return v, p.errorAt(col, "has no value after the comparison")
+	}
+	text, num, rest := scanNumber(tokens)
+	if !num {
+		text, rest = tokens[0].text, tokens[1:]
+		switch tokens[0].tok {
+		case scanner.String:
+			text = strings.Trim(text, `"`)
+		case scanner.RawString:
+			text = strings.Trim(text, "`")
+		}
+	}
+	v.text = text
+	if num && len(rest) > 0 && rest[0].tok == '~' {
+		tol, isNum, tolRest := scanNumber(rest[1:])
+		if !isNum {
+			return v, p.errorAt(rest[0].col, "has tolerance without a number")
+		}
+		f, err := strconv.ParseFloat(tol, 64)
+		if err != nil {
+			return v, p.errorAt(rest[0].col, "%v", err)
+		}
+		v.tolerance, v.hasTolerance, rest = math.Abs(f), true, tolRest
+	}
+	if len(rest) > 0 {
+		return v, p.errorAt(rest[0].col, "contains tokens past the comparison (%v)", rest[0].text)
+	}
+	return v, nil
+}
+
+// scanNumber answers the text of an optionally
+// signed number at the start of tokens, and the
+// remaining tokens.
+func scanNumber(tokens []token) (string, bool, []token) {
+	sign := ""
+	if len(tokens) > 0 && (tokens[0].tok == '-' || tokens[0].tok == '+') {
+		sign, tokens = tokens[0].text, tokens[1:]
+	}
+	if len(tokens) > 0 && (tokens[0].tok == scanner.Int || tokens[0].tok == scanner.Float) {
+		return sign + tokens[0].text, true, tokens[1:]
+	}
+	return "", false, tokens
+}
+
+// peek answers the token i past the current token.
+func (p *parser) peek(i int) (token, bool) {
+	if p.pos+i >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos+i], true
+}
+
+// adjacent answers true if the token i past the current token
+// is immediately followed by the char, with nothing in between.
+func (p *parser) adjacent(i int, ch rune) bool {
+	t, ok := p.peek(i)
+	next, nextOk := p.peek(i + 1)
+	return ok && nextOk && next.tok == ch && next.offset == t.end()
+}
+
+// acceptPair consumes two adjacent chars, i.e. "&&".
+func (p *parser) acceptPair(ch rune) bool {
+	t, ok := p.peek(0)
+	if !ok || t.tok != ch || !p.adjacent(0, ch) {
+		return false
+	}
+	p.pos += 2
+	return true
+}
+
+// textFrom answers the source from the start of the
+// token to the end of the last consumed token.
+func (p *parser) textFrom(start token) string {
+	if p.pos < 1 {
+		return ""
+	}
+	return strings.TrimSpace(p.src[start.offset:p.tokens[p.pos-1].end()])
+}
+
+// endCol answers the column past the end of the source.
+func (p *parser) endCol() int {
+	return len([]rune(p.src)) + 1
+}
+
+func (p *parser) errorAt(col int, format string, args ...any) error {
+	return fmt.Errorf("expr \"%v\" col %v: %v", p.src, col, fmt.Sprintf(format, args...))
+}
+
+// isTermEnd answers true if the token can't be part of a
+// term: a boolean operator, closing parenthesis or bracket.
+func isTermEnd(t token) bool {
+	return t.tok == '&' || t.tok == '|' || t.tok == ')' || t.tok == ']'
+}
+
+func isIdentRune(ch rune, i int) bool {
+	// This is the standard text scanner ident rune, plus "{" and "}"
+	// for keywords.
+	ident := ch == '_' || ch == '{' || ch == '}' || unicode.IsLetter(ch) || (unicode.IsDigit(ch) && i > 0)
+	return ident
+}
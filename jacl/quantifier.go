@@ -0,0 +1,226 @@
+package jacl
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// quantifier determines how a term aggregates the
+// comparisons of many values.
+type quantifier int
+
+const (
+	noQuantifier quantifier = iota
+	allQuantifier
+	anyQuantifier
+	noneQuantifier
+)
+
+// aggregates answers true if the term compares many values,
+// i.e. it has a wildcard or quantifier.
+func (r *runner) aggregates() bool {
+	return r.wild || r.quantifier != noQuantifier
+}
+
+// handleWildcard replaces each node with its children.
+func (r *runner) handleWildcard() {
+	r.wild = true
+	next := make([]node, 0, len(r.nodes))
+	for _, n := range r.nodes {
+		children, err := getChildren(n.value)
+		if err != nil {
+			if !n.deep {
+				r.misses = append(r.misses, miss{path: joinPath(n.path, "*"), err: err})
+			}
+			continue
+		}
+		for _, c := range children {
+			next = append(next, node{path: joinPath(n.path, c.name), value: c.value, deep: n.deep})
+		}
+	}
+	r.nodes = next
+}
+
+// handlePredicate replaces each node with its
+// children that match the predicate.
+func (r *runner) handlePredicate(pred matcher) {
+	r.handleWildcard()
+	next := make([]node, 0, len(r.nodes))
+	for _, n := range r.nodes {
+		if pred.match(r.opts, n.value, nil) == nil {
+			next = append(next, n)
+		}
+	}
+	r.nodes = next
+}
+
+// handleDeepWildcard replaces each node with itself
+// and all of its descendants.
+func (r *runner) handleDeepWildcard() {
+	r.wild = true
+	next := make([]node, 0, len(r.nodes))
+	visited := make(map[visitKey]bool)
+	var walk func(n node)
+	walk = func(n node) {
+		if key, ok := getVisitKey(n.value); ok {
+			if visited[key] {
+				return
+			}
+			visited[key] = true
+		}
+		next = append(next, n)
+		children, _ := getChildren(n.value)
+		for _, c := range children {
+			walk(node{path: joinPath(n.path, c.name), value: c.value, deep: true})
+		}
+	}
+	for _, n := range r.nodes {
+		n.deep = true
+		walk(n)
+	}
+	r.nodes = next
+}
+
+// finish compares every node against the value, and
+// aggregates the results with the quantifier. An empty
+// op only requires the path to exist. Values that can't
+// be compared are failures.
+func (r *runner) finish(op compareOp, want compareValue) error {
+	if !r.aggregates() {
+		if op == "" {
+			return nil
+		}
+		return r.handleCompare(r.nodes[0].value, op, want)
+	}
+	var matches, failures []string
+	var matched, failed []Failure
+	for _, n := range r.nodes {
+		if op == "" {
+			matches = append(matches, formatPath(n.path))
+			matched = append(matched, Failure{Path: n.path, Value: n.value, HasValue: true, Message: "exists"})
+			continue
+		}
+		ok, err := r.compare(n.value, op, want)
+		if err != nil {
+			failures = append(failures, formatPath(n.path)+" "+err.Error())
+			failed = append(failed, Failure{Path: n.path, Value: n.value, HasValue: true, Message: err.Error()})
+		} else if ok {
+			matches = append(matches, formatPath(n.path))
+			matched = append(matched, Failure{Path: n.path, Value: n.value, HasValue: true, Message: "matches"})
+		} else {
+			failures = append(failures, formatPath(n.path)+" "+mismatch(n.value, op, want))
+			failed = append(failed, Failure{Path: n.path, Value: n.value, HasValue: true, Message: wants(op, want)})
+		}
+	}
+	for _, m := range r.misses {
+		failures = append(failures, formatPath(m.path)+" "+m.err.Error())
+		failed = append(failed, Failure{Path: m.path, Message: m.err.Error()})
+	}
+
+	switch r.quantifier {
+	case anyQuantifier:
+		if len(matches) < 1 && len(failures) < 1 {
+			r.fail(Failure{Message: "has no values"})
+			return fmt.Errorf("Term \"%v\" has no values", r.currentTerm)
+		} else if len(matches) < 1 {
+			r.failAll(failed)
+			return fmt.Errorf("Term \"%v\" has no match: %v", r.currentTerm, strings.Join(failures, "; "))
+		}
+	case noneQuantifier:
+		if len(matches) > 0 {
+			r.failAll(matched)
+			return fmt.Errorf("Term \"%v\" matches at %v", r.currentTerm, strings.Join(matches, ", "))
+		}
+	default:
+		if len(failures) > 0 {
+			r.failAll(failed)
+			return fmt.Errorf("Term \"%v\" fails at %v", r.currentTerm, strings.Join(failures, "; "))
+		} else if len(matches) < 1 {
+			r.fail(Failure{Message: "has no values"})
+			return fmt.Errorf("Term \"%v\" has no values", r.currentTerm)
+		}
+	}
+	return nil
+}
+
+func (r *runner) failAll(failures []Failure) {
+	for _, f := range failures {
+		r.fail(f)
+	}
+}
+
+// ---------------------------------------------------------
+// SUPPORT
+
+// child is a named value inside a container.
+type child struct {
+	name  string
+	value any
+}
+
+// getChildren answers the elements of a slice, array or map,
+// or the exported fields of a struct. Maps are sorted by key.
+func getChildren(target any) ([]child, error) {
+	v, err := indirect(reflect.ValueOf(target))
+	if err != nil {
+		return nil, fmt.Errorf("Can't navigate to \"*\" on %w", err)
+	}
+	var children []child
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			children = append(children, child{name: strconv.Itoa(i), value: v.Index(i).Interface()})
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		slices.SortFunc(keys, compareKeys)
+		for _, k := range keys {
+			children = append(children, child{name: fmt.Sprintf("%v", k.Interface()), value: v.MapIndex(k).Interface()})
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Type().Field(i); f.IsExported() {
+				children = append(children, child{name: f.Name, value: v.Field(i).Interface()})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("Can't navigate to \"*\" on kind %v", v.Kind())
+	}
+	return children, nil
+}
+
+// visitKey identifies a reference value, to
+// prevent walking cycles.
+type visitKey struct {
+	t   reflect.Type
+	ptr uintptr
+}
+
+func getVisitKey(target any) (visitKey, bool) {
+	v := reflect.ValueOf(target)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if v.IsNil() || (v.Kind() == reflect.Slice && v.Len() < 1) {
+			return visitKey{}, false
+		}
+		return visitKey{t: v.Type(), ptr: v.Pointer()}, true
+	}
+	return visitKey{}, false
+}
+
+// formatPath answers the path for error reporting.
+func formatPath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+var quantifiers = map[string]quantifier{
+	keywordAll:  allQuantifier,
+	keywordAny:  anyQuantifier,
+	keywordNone: noneQuantifier,
+}
@@ -0,0 +1,183 @@
+package jacl
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// T runs the terms against the target in reporting mode, and
+// fails the test with every failure. Errors are attributed to
+// the caller's line.
+func T(t testing.TB, target any, terms ...string) {
+	t.Helper()
+	expr, err := Compile(terms...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep := expr.Report(target); rep != nil {
+		t.Error(rep)
+	}
+}
+
+// RunReport compares a list of terms against a target, like Run,
+// but evaluates every term and answers a *Report with every failure.
+// Compile errors are returned as-is.
+func RunReport(target any, terms ...string) error {
+	return RunReportOpts(Opts{}, target, terms...)
+}
+
+// RunReportOpts is RunReport with options, see Opts docs.
+func RunReportOpts(opts Opts, target any, terms ...string) error {
+	expr, err := CompileOpts(opts, terms...)
+	if err != nil {
+		return err
+	}
+	if rep := expr.Report(target); rep != nil {
+		return rep
+	}
+	return nil
+}
+
+// Report compares the target against every term, answering
+// all failures, or nil if every term matches.
+func (e *Expr) Report(target any) *Report {
+	rep := &Report{}
+	for _, m := range e.terms {
+		m.match(e.opts, target, rep)
+	}
+	if len(rep.Failures) < 1 {
+		return nil
+	}
+	return rep
+}
+
+// Report is a list of failures.
+type Report struct {
+	Failures []Failure
+}
+
+// Error answers every failure, one per line.
+func (r *Report) Error() string {
+	lines := make([]string, 0, len(r.Failures))
+	for _, f := range r.Failures {
+		lines = append(lines, f.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r *Report) add(failures ...Failure) {
+	if r != nil {
+		r.Failures = append(r.Failures, failures...)
+	}
+}
+
+// Failure describes a single value that failed a term.
+type Failure struct {
+	// Term is the source of the failed term.
+	Term string
+	// Path is the resolved path to the value, i.e. "Items/2/Name"
+	// for the term "Items/*/Name=a". It is empty if the failure
+	// applies to the whole term.
+	Path string
+	// Value is the actual value at the path. It is only
+	// valid if HasValue is true.
+	Value    any
+	HasValue bool
+	// Message describes the failure.
+	Message string
+}
+
+func (f Failure) String() string {
+	sb := strings.Builder{}
+	fmt.Fprintf(&sb, "Term \"%v\"", f.Term)
+	if f.Path != "" || f.HasValue {
+		fmt.Fprintf(&sb, " at %v", formatPath(f.Path))
+	}
+	sb.WriteString(": ")
+	if f.HasValue {
+		fmt.Fprintf(&sb, "has %#v, ", f.Value)
+	}
+	sb.WriteString(f.Message)
+	return sb.String()
+}
+
+// ---------------------------------------------------------
+// SUGGESTIONS
+
+// suggest answers a "did you mean" for the nearest
+// candidate to name, or an empty string if none are near.
+func suggest(name string, candidates []string) string {
+	best, bestDist := "", -1
+	for _, c := range candidates {
+		d := editDistance(strings.ToLower(name), strings.ToLower(c))
+		if d <= maxSuggestDistance(name) && (bestDist < 0 || d < bestDist) {
+			best, bestDist = c, d
+		}
+	}
+	if bestDist < 0 {
+		return ""
+	}
+	return "; did you mean " + best
+}
+
+// maxSuggestDistance answers the furthest a suggestion can be
+// from name, scaled so short names only match by case.
+func maxSuggestDistance(name string) int {
+	return utf8.RuneCountInString(name) / 3
+}
+
+// editDistance answers the Levenshtein distance between a
+// and b, counting a transposition of adjacent runes as one edit.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	// d[i][j] is the distance between ra[:i] and rb[:j].
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+// getFieldNames answers the exported fields of the struct.
+func getFieldNames(v reflect.Value) []string {
+	var names []string
+	for i := 0; i < v.NumField(); i++ {
+		if f := v.Type().Field(i); f.IsExported() {
+			names = append(names, f.Name)
+		}
+	}
+	return names
+}
+
+// getKeyNames answers the string keys of the map, sorted.
+func getKeyNames(v reflect.Value) []string {
+	var names []string
+	if v.Type().Key().Kind() != reflect.String {
+		return names
+	}
+	for _, k := range v.MapKeys() {
+		names = append(names, k.String())
+	}
+	slices.Sort(names)
+	return names
+}
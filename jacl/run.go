@@ -3,26 +3,49 @@ package jacl
 import (
 	"fmt"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
-	"text/scanner"
-	"unicode"
-
-	"github.com/hackborn/onefunc/errors"
 )
 
 // Run compares a list of terms against a target. Target can be anything.
 // Terms are described by the grammar
 // {term} = {path}{comparison_operator}{value}
 // Where {path} is a "/" separated list of identifiers
-// {comparison_operator} is "="
-// {value} is a string
-// {path} identifiers can be either an integer to index slices, or
-// a string for named fields.
+// {comparison_operator} is one of
+//
+//	"=" equal
+//	"!=" not equal
+//	"<", "<=", ">", ">=" ordered, for numbers and strings
+//	"~=" matches the regular expression
+//	"^=" has the prefix
+//	"$=" has the suffix
+//	"*=" contains
+//
+// {value} is a string. Quoted values can contain any character;
+// raw (`) strings are useful for regular expressions.
+// Neither {path} nor {value} can be empty: use "/" for the target
+// itself, `""` for an empty string, and a {path} with no comparison
+// to only require that the path exists. (Earlier versions treated
+// "Name=" as a path check and "=a" as a comparison of the target;
+// both are now syntax errors.)
+// {path} identifiers can be either an integer to index slices and
+// arrays, or a string for named fields, including fields promoted
+// from embedded structs. Map keys can be any string or int kind.
+// Pointers and interfaces are followed. Exported methods that take
+// no arguments, like "Len" or "String", can also be named; they
+// answer their value, and optionally an error.
 // Example term:
 // "0/Name=Ireland"
 // where the target is a slice of structs that have a Name field.
 //
+// Numbers are compared by value across all int, uint and float
+// kinds. A float comparison can include a tolerance after a "~":
+//
+//	"Weight=1.5~0.01" will be true if Weight is within 0.01 of 1.5.
+//
+// Without one, Opts.Tolerance is used.
+//
 // Builtin keywords are supported for specific comparisons:
 // "{type}" will compare against the type, i.e.
 //
@@ -31,6 +54,41 @@ import (
 // "{count}" will compare against the length of slices and maps, i.e.
 //
 //	"{count}=2" will be true if the value is a slice with length of 2.
+//
+// "{keys}" will compare against the sorted keys of a map, joined with ",", i.e.
+//
+//	`{keys}="a,b"` will be true if the value is a map with keys a and b.
+//
+// "{nil}" will compare against whether the value is nil, i.e.
+//
+//	"Parent/{nil}=true" will be true if the Parent pointer is nil.
+//
+// Path wildcards compare many values with one term:
+// "*" is every element of a slice, array or map, or every
+// exported field of a struct. "**" is the value and everything
+// below it, at any depth. For example:
+//
+//	"Items/*/Name=a" will be true if every item has Name a.
+//	"**/Enabled=true" will be true if every Enabled field is true.
+//
+// A predicate in brackets is a wildcard for only the children that
+// match an expression:
+//
+//	"Items/[Kind=fruit]/Name=apple" will be true if every fruit item has Name apple.
+//
+// Values that can't navigate the rest of the path fail the term,
+// except below "**", where they are skipped. Quantifier keywords,
+// usually at the start of the path, change how the values are aggregated:
+//
+//	"{all}" every value must match, and there must be at least one. This is the default.
+//	"{any}" at least one value must match.
+//	"{none}" no value can match.
+//
+// i.e. "{any}/Items/*/Name=a" will be true if some item has Name a.
+//
+// Terms can be combined with "&&", "||", "!" and parentheses, see
+// Compile docs. To match the same terms against many targets,
+// compile them once with Compile.
 func Run(target any, terms ...string) error {
 	return RunOpts(Opts{}, target, terms...)
 }
@@ -39,14 +97,11 @@ func Run(target any, terms ...string) error {
 // See Run docs for a decription of target and terms.
 // Opts adds some configuration options, see Opts docs for a description.
 func RunOpts(opts Opts, target any, terms ...string) error {
-	for _, term := range terms {
-		r := &runner{opts: opts, target: target}
-		err := r.runTerm(term)
-		if err != nil {
-			return err
-		}
+	e, err := CompileOpts(opts, terms...)
+	if err != nil {
+		return err
 	}
-	return nil
+	return e.Match(target)
 }
 
 // RunErr compares two errors, returning if they do not match.
@@ -64,229 +119,350 @@ func RunErr(have, want error) error {
 }
 
 type runner struct {
-	first  errors.FirstBlock
-	opts   Opts
-	target any
+	opts Opts
+	// nodes are the current targets. There is one until
+	// a wildcard is reached.
+	nodes []node
+	// misses are the nodes that failed to navigate the
+	// path, once the term aggregates.
+	misses     []miss
+	quantifier quantifier
+	wild       bool
+	// failures describe every way the term failed. They
+	// are only used by reports.
+	failures []Failure
 	// currentTerm is only stored for error reporting.
 	currentTerm string
 }
 
-func (r *runner) runTerm(term string) error {
-	r.currentTerm = term
-	var scan scanner.Scanner
-	scan.Init(strings.NewReader(term))
-	scan.Whitespace = 0
-	scan.Mode = scanner.ScanChars | scanner.ScanComments | scanner.ScanFloats | scanner.ScanIdents | scanner.ScanInts | scanner.ScanRawStrings | scanner.ScanStrings
-	scan.IsIdentRune = r.isIdentRune
-	scan.Error = func(s *scanner.Scanner, msg string) {
-		r.first.AddError(fmt.Errorf("run error: %v", msg))
-	}
-	stage := noCompare
-
-	for tok := scan.Scan(); tok != scanner.EOF; tok = scan.Scan() {
-		if r.first.Err != nil {
-			return r.first.Err
-		}
-		// fmt.Println("TOK", tok, "name", scanner.TokenString(tok), "text", lexer.TokenText())
-
-		// Always skip whitespace, whatever the stage
-		switch tok {
-		case ' ', '\r', '\t', '\n':
-			continue
-		}
-
-		text := scan.TokenText()
-		// If there are any tokens past the comparison, error
-		if stage == finishedCompare {
-			return fmt.Errorf("expr \"%v\" contains tokens past the comparison (%v)", term, text)
-		}
-		if stage == runCompare {
-			r.first.AddError(r.handleCompare(tok, text))
-			stage = finishedCompare
-			continue
-		}
-		if text == "=" {
-			if stage != noCompare {
-				return fmt.Errorf("expr \"%v\" has multiple comparisons", term)
-			}
-			stage = runCompare
-			continue
+// run navigates the path of the term and compares the values.
+func (r *runner) run(t *term) error {
+	for _, seg := range t.segments {
+		if err := r.handleSegment(seg); err != nil {
+			return err
 		}
-
-		r.first.AddError(r.handlePath(tok, text))
 	}
-	return r.first.Err
+	return r.finish(t.op, t.want)
 }
 
-func (r *runner) isIdentRune(ch rune, i int) bool {
-	// This is the standard text scanner ident rune, plus "{" and "}"
-	// for keywords.
-	ident := ch == '_' || ch == '{' || ch == '}' || unicode.IsLetter(ch) || (unicode.IsDigit(ch) && i > 0)
-	return ident
+// fail records a failure for the report.
+func (r *runner) fail(f Failure) {
+	f.Term = r.currentTerm
+	r.failures = append(r.failures, f)
 }
 
-func (r *runner) handleCompare(tok rune, t string) error {
-	switch tok {
-	//		case scanner.Float:
-	//			tt.tt = floatToken
-	case scanner.Int:
-		t = strings.Trim(t, `"`)
-		return r.handleAnyCompare(t)
-	case scanner.String:
-		t = strings.Trim(t, `"`)
-		return r.handleAnyCompare(t)
-	case scanner.Ident:
-		return r.handleAnyCompare(t)
-	default:
-		return r.handleAnyCompare(t)
-	}
+// node is a target value, along with the resolved
+// path to it for error reporting.
+type node struct {
+	path  string
+	value any
+	// deep is true for nodes found by "**". They are dropped,
+	// rather than missed, if they can't navigate the path.
+	deep bool
 }
 
-func (r *runner) handleAnyCompare(s string) error {
-	switch cmp := r.target.(type) {
-	case bool:
-		s = strings.ToLower(s)
-		if cmp == true && (s == "t" || s == "true") {
-			return nil
-		} else if cmp == false && (s == "f" || s == "false") {
-			return nil
-		} else {
-			return fmt.Errorf("Term \"%v\" has value \"%v\" but wants \"%v\"", r.currentTerm, cmp, s)
-		}
-	case int, uint, int8, uint8, int16, uint16, int32, uint32, int64, uint64:
-		// There's got to be a clean way to do a direct conversion but I haven't found it.
-		a, erra := strconv.ParseInt(fmt.Sprintf("%v", cmp), 10, 64)
-		b, errb := strconv.ParseInt(s, 10, 64)
-		if erra != nil || errb != nil {
-			return fmt.Errorf("Can't compare int %v to %v (%v %v)", r.target, s, erra, errb)
-		}
-		if a != b {
-			return fmt.Errorf("Have int %v but want %v", cmp, s)
-		}
-	case string:
-		if cmp != r.opts.processValue(s) {
-			return fmt.Errorf("Term \"%v\" has value \"%v\" but wants \"%v\"", r.currentTerm, cmp, s)
-		}
+// miss is a node that couldn't navigate the path.
+type miss struct {
+	path string
+	err  error
+}
+
+// handleSegment navigates every node along the segment.
+func (r *runner) handleSegment(seg segment) error {
+	switch seg.kind {
+	case wildcardSegment:
+		r.handleWildcard()
+		return nil
+	case deepWildcardSegment:
+		r.handleDeepWildcard()
+		return nil
+	case predicateSegment:
+		r.handlePredicate(seg.pred)
+		return nil
+	case indexSegment:
+		return r.navigate(seg.name, func(target any) (any, error) {
+			return r.handlePathInt(target, seg.index)
+		})
 	default:
-		// Not sure if this is the best way to handle this, but for unknown types
-		// convert them to string and compare. It's the only way I can think of
-		// to handle custom types like bitmasks.
-		if fmt.Sprintf("%v", cmp) != s {
-			return fmt.Errorf("Term \"%v\" has value \"%v\" but wants \"%v\"", r.currentTerm, cmp, s)
-		}
-		//		return fmt.Errorf("Can't compare %v with %v", r.target, s)
+		return r.navigate(seg.name, func(target any) (any, error) {
+			return r.handlePathString(target, seg.name)
+		})
 	}
-	return nil
 }
 
-func (r *runner) handlePath(tok rune, t string) error {
-	switch tok {
-	case scanner.Float:
-		return fmt.Errorf("Can't navigate to float \"%v\"", t)
-	case scanner.Int:
-		i, err := strconv.Atoi(t)
-		if err != nil {
+// navigate replaces each node with the result of step. Once
+// the term aggregates, nodes that can't step are missed instead
+// of failing the term.
+func (r *runner) navigate(name string, step func(any) (any, error)) error {
+	next := make([]node, 0, len(r.nodes))
+	for _, n := range r.nodes {
+		path := joinPath(n.path, name)
+		v, err := step(n.value)
+		if err == nil {
+			next = append(next, node{path: path, value: v, deep: n.deep})
+		} else if !r.aggregates() {
+			r.fail(Failure{Path: path, Message: err.Error()})
 			return err
+		} else if !n.deep {
+			r.misses = append(r.misses, miss{path: path, err: err})
 		}
-		return r.handlePathInt(i)
-	case scanner.String:
-		t = strings.Trim(t, `"`)
-		return r.handlePathString(t)
-	case scanner.Ident:
-		return r.handlePathString(t)
-	default:
-		if t == "/" {
-			// Path separator, continue
-			return nil
-		}
-		return fmt.Errorf("Can't navigate to \"%v\"", t)
 	}
+	r.nodes = next
+	return nil
 }
 
-func (r *runner) handlePathInt(i int) error {
-	targetValue := reflect.ValueOf(r.target)
+func (r *runner) handlePathInt(target any, i int) (any, error) {
+	targetValue, err := indirect(reflect.ValueOf(target))
+	if err != nil {
+		return nil, fmt.Errorf("Can't navigate to int \"%v\" on %w", i, err)
+	}
 	switch targetValue.Kind() {
-	case reflect.Slice:
-		return r.handlePathIntOnSlice(i)
+	case reflect.Slice, reflect.Array:
+		return r.handlePathIntOnSlice(targetValue, i)
+	case reflect.Map:
+		return r.handlePathKeyOnMap(targetValue, strconv.Itoa(i))
 	default:
-		return fmt.Errorf("Can't navigate to int \"%v\" on kind %v", i, targetValue.Kind())
+		return nil, fmt.Errorf("Can't navigate to int \"%v\" on kind %v", i, targetValue.Kind())
 	}
 }
 
-func (r *runner) handlePathIntOnSlice(i int) error {
-	// We know r.dst is Kind slice
-	sliceValue := reflect.ValueOf(r.target)
+func (r *runner) handlePathIntOnSlice(sliceValue reflect.Value, i int) (any, error) {
+	// We know sliceValue is Kind slice or array
 	if i >= sliceValue.Len() {
-		return fmt.Errorf("Index %v is out of range on slice with len %v", i, sliceValue.Len())
+		return nil, fmt.Errorf("Index %v is out of range on %v with len %v", i, sliceValue.Kind(), sliceValue.Len())
 	}
 	v := sliceValue.Index(i)
-	r.target = v.Interface()
-	return nil
+	return v.Interface(), nil
 }
 
-func (r *runner) handlePathString(s string) error {
+func (r *runner) handlePathString(target any, s string) (any, error) {
 	// Intercept keywords
-	if s == keywordType {
-		r.target = getTypeName(r.target)
-		return nil
-	} else if s == keywordCount {
-		return r.handlePathCount()
+	switch s {
+	case keywordType:
+		if r.opts.JSONTypes {
+			return getJSONTypeName(target), nil
+		}
+		return getTypeName(target), nil
+	case keywordNil:
+		return isNil(reflect.ValueOf(target)), nil
+	case keywordCount:
+		return r.handlePathCount(target)
+	case keywordKeys:
+		return r.handlePathKeys(target)
 	}
 
-	targetValue := reflect.ValueOf(r.target)
+	targetValue, err := indirect(reflect.ValueOf(target))
+	if err != nil {
+		return nil, fmt.Errorf("Can't navigate to string \"%v\" on %w", s, err)
+	}
 	switch targetValue.Kind() {
 	case reflect.Struct:
-		return r.handlePathStringOnStruct(s)
-	case reflect.Ptr:
-		elem := targetValue.Elem()
-		r.target = elem.Interface()
-		return r.handlePathString(s)
+		if v, ok, err := r.handlePathStringOnStruct(targetValue, s); ok || err != nil {
+			return v, err
+		}
 	case reflect.Map:
-		return r.handlePathStringOnMap(s)
-	default:
-		return fmt.Errorf("Can't navigate to string \"%v\" on kind %v", s, targetValue.Kind())
+		if v, err := r.handlePathKeyOnMap(targetValue, s); err == nil || !hasMethod(target, s) {
+			return v, err
+		}
 	}
+	if v, ok, err := r.handlePathMethod(target, s); ok {
+		return v, err
+	}
+	if targetValue.Kind() == reflect.Struct {
+		return nil, fmt.Errorf("no field %v on %v%v", s, targetValue.Type(), suggest(s, getFieldNames(targetValue)))
+	}
+	return nil, fmt.Errorf("Can't navigate to string \"%v\" on kind %v", s, targetValue.Kind())
 }
 
-func (r *runner) handlePathStringOnStruct(fieldName string) error {
-	// We know r.target is Kind struct
-	structValue := reflect.ValueOf(r.target)
-	field := structValue.FieldByName(fieldName)
-	if !field.IsValid() {
-		return fmt.Errorf("no field for %v on struct %v", fieldName, r.target)
+// handlePathStringOnStruct answers the field, including
+// fields promoted from embedded structs. It answers false
+// if there is no field.
+func (r *runner) handlePathStringOnStruct(structValue reflect.Value, fieldName string) (any, bool, error) {
+	// We know structValue is Kind struct
+	sf, ok := structValue.Type().FieldByName(fieldName)
+	if !ok || !sf.IsExported() {
+		return nil, false, nil
 	}
-	r.target = field.Interface()
-	return nil
+	field, err := structValue.FieldByIndexErr(sf.Index)
+	if err != nil {
+		return nil, true, fmt.Errorf("Can't navigate to field \"%v\" on %v: %w", fieldName, structValue.Type(), err)
+	}
+	return field.Interface(), true, nil
 }
 
-func (r *runner) handlePathStringOnMap(fieldName string) error {
-	// We know r.target is Kind map
-	mapValue := reflect.ValueOf(r.target)
-	field := mapValue.MapIndex(reflect.ValueOf(fieldName))
+// handlePathKeyOnMap answers the value for the key, converted
+// to the type of the map keys, which can be any string or int kind.
+func (r *runner) handlePathKeyOnMap(mapValue reflect.Value, key string) (any, error) {
+	// We know mapValue is Kind map
+	keyValue, err := convertKey(key, mapValue.Type().Key())
+	if err != nil {
+		return nil, err
+	}
+	field := mapValue.MapIndex(keyValue)
 	if !field.IsValid() {
-		return fmt.Errorf("no field for %v on map %v", fieldName, r.target)
+		return nil, fmt.Errorf("no key %v on %v%v", key, mapValue.Type(), suggest(key, getKeyNames(mapValue)))
 	}
-	r.target = field.Interface()
-	return nil
+	return field.Interface(), nil
 }
 
-func (r *runner) handlePathCount() error {
-	v := reflect.ValueOf(r.target)
+// handlePathMethod answers the result of calling the named method,
+// which must take no arguments and answer a value, and optionally
+// an error. It answers false if there is no method.
+func (r *runner) handlePathMethod(target any, name string) (any, bool, error) {
+	method, ok := getMethod(target, name)
+	if !ok {
+		return nil, false, nil
+	}
+	mt := method.Type()
+	if mt.NumIn() != 0 || mt.NumOut() < 1 || mt.NumOut() > 2 || (mt.NumOut() == 2 && mt.Out(1) != errorType) {
+		return nil, true, fmt.Errorf("Can't call method \"%v\" on %T, it must have no arguments and answer a value", name, target)
+	}
+	out := method.Call(nil)
+	if len(out) == 2 && !out[1].IsNil() {
+		return nil, true, fmt.Errorf("method \"%v\" on %T: %w", name, target, out[1].Interface().(error))
+	}
+	return out[0].Interface(), true, nil
+}
+
+func (r *runner) handlePathCount(target any) (any, error) {
+	v, err := indirect(reflect.ValueOf(target))
+	if err != nil {
+		return nil, fmt.Errorf("%v on %w", keywordCount, err)
+	}
 	switch v.Kind() {
 	case reflect.Array, reflect.Chan, reflect.Map, reflect.Slice, reflect.String:
-		r.target = v.Len()
-		return nil
+		return v.Len(), nil
 	default:
-		return fmt.Errorf("%v on invalid type %T", keywordCount, r.target)
+		return nil, fmt.Errorf("%v on invalid type %T", keywordCount, target)
 	}
 }
 
+// handlePathKeys answers the sorted keys of a map,
+// joined with ",".
+func (r *runner) handlePathKeys(target any) (any, error) {
+	v, err := indirect(reflect.ValueOf(target))
+	if err != nil {
+		return nil, fmt.Errorf("%v on %w", keywordKeys, err)
+	}
+	if v.Kind() != reflect.Map {
+		return nil, fmt.Errorf("%v on invalid type %T", keywordKeys, target)
+	}
+	keys := make([]reflect.Value, 0, v.Len())
+	keys = append(keys, v.MapKeys()...)
+	slices.SortFunc(keys, compareKeys)
+	names := make([]string, 0, len(keys))
+	for _, k := range keys {
+		names = append(names, fmt.Sprintf("%v", k.Interface()))
+	}
+	return strings.Join(names, ","), nil
+}
+
 // ---------------------------------------------------------
 // SUPPORT
 
+// joinPath answers the path with name appended.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "/" + name
+}
+
+// indirect answers the value that v points to, through any
+// number of pointers and interfaces.
+func indirect(v reflect.Value) (reflect.Value, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return v, fmt.Errorf("nil %v", v.Type())
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return v, fmt.Errorf("nil")
+	}
+	return v, nil
+}
+
+// isNil answers true if v is nil, or a nil pointer,
+// interface, map, slice, func or chan.
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	}
+	return false
+}
+
+// convertKey answers the key converted to the type,
+// which can be any string, int or uint kind.
+func convertKey(key string, t reflect.Type) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.String:
+		v.SetString(key)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(key, 10, t.Bits())
+		if err != nil {
+			return v, fmt.Errorf("Can't use key \"%v\" on map with %v keys", key, t)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(key, 10, t.Bits())
+		if err != nil {
+			return v, fmt.Errorf("Can't use key \"%v\" on map with %v keys", key, t)
+		}
+		v.SetUint(u)
+	default:
+		return v, fmt.Errorf("Can't use key \"%v\" on map with %v keys", key, t)
+	}
+	return v, nil
+}
+
+// compareKeys orders map keys, numerically for
+// numbers and by their string otherwise.
+func compareKeys(a, b reflect.Value) int {
+	if na, ok := valueNumber(a); ok {
+		if nb, ok := valueNumber(b); ok {
+			return compareNumbers(na, nb, 0)
+		}
+	}
+	return strings.Compare(fmt.Sprintf("%v", a.Interface()), fmt.Sprintf("%v", b.Interface()))
+}
+
+// getMethod answers the named exported method of the target,
+// including methods with pointer receivers.
+func getMethod(target any, name string) (reflect.Value, bool) {
+	v := reflect.ValueOf(target)
+	if !v.IsValid() {
+		return reflect.Value{}, false
+	}
+	if m := v.MethodByName(name); m.IsValid() {
+		return m, true
+	}
+	if v.Kind() != reflect.Ptr && v.Kind() != reflect.Interface {
+		// Copy to a pointer for the pointer receiver methods.
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		if m := ptr.MethodByName(name); m.IsValid() {
+			return m, true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func hasMethod(target any, name string) bool {
+	_, ok := getMethod(target, name)
+	return ok
+}
+
 // getTypeName answers the type of a, without the package name.
 func getTypeName(a any) string {
 	t := reflect.TypeOf(a)
+	if t == nil {
+		return "nil"
+	}
 	switch t.Kind() {
 	case reflect.Ptr:
 		return "*" + t.Elem().Name()
@@ -298,15 +474,14 @@ func getTypeName(a any) string {
 // ---------------------------------------------------------
 // CONST and VAR
 
-type compareStage int
-
-const (
-	noCompare       compareStage = iota // No comparison token has been hit
-	runCompare                          // The comparison token was processed; next token is the value
-	finishedCompare                     // The comparison is finished
-)
+var errorType = reflect.TypeFor[error]()
 
 const (
 	keywordType  = `{type}`
 	keywordCount = `{count}`
+	keywordKeys  = `{keys}`
+	keywordNil   = `{nil}`
+	keywordAll   = `{all}`
+	keywordAny   = `{any}`
+	keywordNone  = `{none}`
 )
@@ -0,0 +1,78 @@
+package jacl
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Select answers every value at the path in the target. See Run
+// docs for a description of paths. Paths with wildcards select many
+// values, skipping any that can't navigate the rest of the path.
+// A predicate selects the children that match an expression, i.e.
+//
+//	"Items/[Kind=fruit && Count>0]/Name"
+//
+// answers the Name of every item that is fruit and has a count.
+func Select(target any, path string) ([]any, error) {
+	p := &parser{src: path}
+	m, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	t, ok := m.(*term)
+	if !ok || t.op != "" {
+		return nil, fmt.Errorf("expr \"%v\" is not a path", path)
+	}
+	r := &runner{nodes: []node{{value: target}}, currentTerm: t.text}
+	for _, seg := range t.segments {
+		if err := r.handleSegment(seg); err != nil {
+			return nil, err
+		}
+	}
+	values := make([]any, 0, len(r.nodes))
+	for _, n := range r.nodes {
+		values = append(values, n.value)
+	}
+	return values, nil
+}
+
+// Get answers the single value at the path in the target,
+// converted to T. Numbers convert to any number type, unless
+// a float with a fraction is answered as an integer, and named
+// types to their underlying type, i.e. a string kind can be
+// answered as a string.
+func Get[T any](target any, path string) (T, error) {
+	var t T
+	values, err := Select(target, path)
+	if err != nil {
+		return t, err
+	}
+	if len(values) != 1 {
+		return t, fmt.Errorf("path \"%v\" selects %v values but wants 1", path, len(values))
+	}
+	if v, ok := values[0].(T); ok {
+		return v, nil
+	}
+	tt := reflect.TypeFor[T]()
+	v := reflect.ValueOf(values[0])
+	switch {
+	case !v.IsValid():
+		if isNil(reflect.Zero(tt)) {
+			return t, nil
+		}
+	case v.Kind() == tt.Kind() && v.CanConvert(tt):
+		return v.Convert(tt).Interface().(T), nil
+	default:
+		have, isNum := valueNumber(v)
+		want, wantNum := valueNumber(reflect.Zero(tt))
+		if isNum && wantNum {
+			// Converting a float to an integer must not lose the fraction.
+			if have.kind == floatNumber && want.kind != floatNumber && (have.f != math.Trunc(have.f) || math.IsInf(have.f, 0)) {
+				return t, fmt.Errorf("path \"%v\" has %v but wants a whole %v", path, values[0], tt)
+			}
+			return v.Convert(tt).Interface().(T), nil
+		}
+	}
+	return t, fmt.Errorf("path \"%v\" has %T but wants %v", path, values[0], tt)
+}
@@ -1,5 +1,9 @@
 package msg
 
+import (
+	"github.com/hackborn/onefunc/sync"
+)
+
 // Subscribe to the topic with the given function. Answer
 // the subscription. Use the subscription to unsubscribe.
 // The last message(s) publshed to the pattern will be immediately
@@ -8,27 +12,97 @@ package msg
 // Pattern is a hierarchy with / separators.
 // Wildcard "+" matches a single level in the hierarchy.
 // Wildcard "#" matches all remaining levels in the hierarchy.
+//
+// Subscribing and collecting the retained messages is atomic with
+// respect to Pub, so a concurrent message is delivered exactly once,
+// either live or replayed. Replayed messages are sent before Sub
+// returns, and a live message that arrives during the replay is held
+// until the replay is done. See Router for ordering guarantees.
 func Sub[T any](r *Router, pattern string, fn HandlerFunc[T]) Subscription {
-	subs := r.sub(pattern, fn)
+	g := &replayGate[T]{fn: fn, replaying: true}
+	sub := subscribe(r, pattern, g.deliver, func(topic string, last T) {
+		g.hold(topic, last)
+	})
+	g.open()
+	return sub
+}
+
+// replayGate holds live messages for a sync subscription
+// until its retained messages are delivered.
+type replayGate[T any] struct {
+	fn        HandlerFunc[T]
+	mut       sync.Mutex
+	replaying bool
+	pending   []message[T]
+}
+
+// deliver sends a live message to the handler, or
+// holds it if the replay isn't done.
+func (g *replayGate[T]) deliver(topic string, value T) {
+	if g.hold(topic, value) {
+		return
+	}
+	g.fn(topic, value)
+}
+
+// hold queues the message if the replay isn't done,
+// answering true if it was queued.
+func (g *replayGate[T]) hold(topic string, value T) bool {
+	defer sync.Lock(&g.mut).Unlock()
+	if g.replaying {
+		g.pending = append(g.pending, message[T]{topic: topic, value: value})
+	}
+	return g.replaying
+}
+
+// open delivers the replayed messages followed by any live
+// messages held in the meantime, then lets live messages through.
+// The handler is called outside the lock so it can publish.
+func (g *replayGate[T]) open() {
+	for {
+		g.mut.Lock()
+		pending := g.pending
+		g.pending = nil
+		if len(pending) < 1 {
+			g.replaying = false
+			g.mut.Unlock()
+			return
+		}
+		g.mut.Unlock()
+		for _, m := range pending {
+			g.fn(m.topic, m.value)
+		}
+	}
+}
+
+// subscribe adds the handler, and sends the retained messages
+// to replay, all within the router lock.
+func subscribe[T any](r *Router, pattern string, fn, replay HandlerFunc[T]) Subscription {
 	retainFn := func(topic string, last any) {
 		if lastT, ok := last.(T); ok {
-			fn(topic, lastT)
+			replay(topic, lastT)
 		}
 	}
-	r.visitRetained(pattern, retainFn)
-	return subs
+	return r.sub(pattern, fn, retainFn)
 }
 
 func Pub[T any](r *Router, topic string, value T) {
 	if r == nil {
 		return
 	}
-	r.readVisit(topic, func(pattern string, subs *routerSubscriptions) {
+	// Handlers are called outside the lock, so they can
+	// subscribe and publish, and so a blocked async queue
+	// doesn't stall other clients of the router. As a result
+	// a handler can be called after its Unsub returns.
+	var handlers []HandlerFunc[T]
+	r.publish(topic, value, func(pattern string, subs *routerSubscriptions) {
 		for _, _h := range subs.subs {
 			if h, ok := _h.(HandlerFunc[T]); ok {
-				h(topic, value)
+				handlers = append(handlers, h)
 			}
 		}
 	})
-	r.retain(topic, value)
+	for _, h := range handlers {
+		h(topic, value)
+	}
 }
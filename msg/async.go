@@ -0,0 +1,200 @@
+package msg
+
+import (
+	"github.com/hackborn/onefunc/sync"
+)
+
+// SubAsync subscribes to the pattern like Sub, but delivers
+// messages on a dedicated goroutine. Publishing adds messages
+// to a bounded queue, and the handler receives them in the order
+// they were queued. Retained messages are queued ahead of any
+// publish, and can exceed the queue size.
+// Unsubscribing stops the goroutine once its queue is empty, and
+// Router.Close() waits for every queue to drain.
+//
+// This lets a background worker publish to subscribers that
+// must stay on their own thread, i.e. a UI.
+func SubAsync[T any](r *Router, pattern string, fn HandlerFunc[T], opts AsyncOpts) Subscription {
+	q := newAsyncQueue(r, fn, opts)
+	r.addQueue(q)
+	go q.run()
+	sub := subscribe(r, pattern, q.push, q.replay)
+	return &asyncSubscription{sub: sub, q: q}
+}
+
+// AsyncOpts configures an async subscription.
+type AsyncOpts struct {
+	// QueueSize is the most messages that can wait for
+	// delivery. Values less than 1 use defaultQueueSize.
+	QueueSize int
+	// Overflow determines what happens when a message
+	// is published to a full queue.
+	Overflow Overflow
+}
+
+// Overflow determines what an async subscription
+// does when its queue is full.
+type Overflow int
+
+const (
+	// OverflowBlock blocks the publisher until there is room.
+	OverflowBlock Overflow = iota
+	// OverflowDropOldest discards the oldest queued message.
+	OverflowDropOldest
+	// OverflowDropNewest discards the message being published.
+	OverflowDropNewest
+)
+
+// Close stops every async subscription, waiting until their
+// queued messages are delivered. Messages published to async
+// subscriptions after Close are dropped.
+func (r *Router) Close() {
+	r.queueMut.Lock()
+	queues := r.queues
+	r.queues = nil
+	r.closed = true
+	r.queueMut.Unlock()
+	for q := range queues {
+		q.close()
+	}
+	for q := range queues {
+		q.wait()
+	}
+}
+
+func (r *Router) addQueue(q queue) {
+	defer sync.Lock(&r.queueMut).Unlock()
+	if r.closed {
+		q.close()
+		return
+	}
+	if r.queues == nil {
+		r.queues = make(map[queue]struct{})
+	}
+	r.queues[q] = struct{}{}
+}
+
+// removeQueue is called by a queue once it's
+// closed and empty.
+func (r *Router) removeQueue(q queue) {
+	defer sync.Lock(&r.queueMut).Unlock()
+	delete(r.queues, q)
+}
+
+// queue is the untyped interface to an asyncQueue.
+type queue interface {
+	// close stops accepting messages. The queue
+	// finishes delivering after it's closed.
+	close()
+	// wait blocks until the queue is closed and empty.
+	wait()
+}
+
+type asyncSubscription struct {
+	sub Subscription
+	q   queue
+}
+
+func (s *asyncSubscription) Unsub() {
+	s.sub.Unsub()
+	s.q.close()
+}
+
+func newAsyncQueue[T any](r *Router, fn HandlerFunc[T], opts AsyncOpts) *asyncQueue[T] {
+	size := opts.QueueSize
+	if size < 1 {
+		size = defaultQueueSize
+	}
+	q := &asyncQueue[T]{r: r, fn: fn, size: size, overflow: opts.Overflow, done: make(chan struct{})}
+	q.cond = sync.NewCond(&q.mut)
+	return q
+}
+
+// asyncQueue delivers messages to a handler on its own goroutine.
+type asyncQueue[T any] struct {
+	r        *Router
+	fn       HandlerFunc[T]
+	size     int
+	overflow Overflow
+
+	mut    sync.Mutex
+	cond   *sync.Cond
+	items  []message[T]
+	closed bool
+	done   chan struct{}
+}
+
+// push adds a message to the queue, applying the overflow
+// policy if it's full. Messages pushed after close are dropped.
+func (q *asyncQueue[T]) push(topic string, value T) {
+	defer sync.Lock(&q.mut).Unlock()
+	for !q.closed && len(q.items) >= q.size {
+		switch q.overflow {
+		case OverflowDropOldest:
+			q.items = q.items[1:]
+		case OverflowDropNewest:
+			return
+		default:
+			q.cond.Wait()
+		}
+	}
+	if q.closed {
+		return
+	}
+	q.items = append(q.items, message[T]{topic: topic, value: value})
+	q.cond.Broadcast()
+}
+
+// replay adds a retained message to the queue. It's called
+// inside the router lock, so it ignores the queue size rather
+// than block, and the replay is queued ahead of any publish.
+func (q *asyncQueue[T]) replay(topic string, value T) {
+	defer sync.Lock(&q.mut).Unlock()
+	if q.closed {
+		return
+	}
+	q.items = append(q.items, message[T]{topic: topic, value: value})
+	q.cond.Broadcast()
+}
+
+// run delivers messages until the queue is closed and empty.
+func (q *asyncQueue[T]) run() {
+	defer close(q.done)
+	defer q.r.removeQueue(q)
+	for {
+		item, ok := q.pop()
+		if !ok {
+			return
+		}
+		q.fn(item.topic, item.value)
+	}
+}
+
+func (q *asyncQueue[T]) pop() (message[T], bool) {
+	defer sync.Lock(&q.mut).Unlock()
+	for len(q.items) < 1 && !q.closed {
+		q.cond.Wait()
+	}
+	if len(q.items) < 1 {
+		return message[T]{}, false
+	}
+	item := q.items[0]
+	// Clear the slot so the value can be collected.
+	q.items[0] = message[T]{}
+	q.items = q.items[1:]
+	// Wake any publishers blocked on a full queue.
+	q.cond.Broadcast()
+	return item, true
+}
+
+func (q *asyncQueue[T]) close() {
+	defer sync.Lock(&q.mut).Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+func (q *asyncQueue[T]) wait() {
+	<-q.done
+}
+
+const defaultQueueSize = 64
@@ -8,6 +8,7 @@ type Channel[T any] interface {
 // Channels are an optimization when a client will be publishing
 // multiple times. It's safe to add or remove subscriptions to
 // the topic after creating a channel, the channel will be updated.
+// A channel must only be used by one goroutine at a time.
 func NewChannel[T any](r *Router, topic string) Channel[T] {
 	c := &_channel[T]{r: r,
 		topic:     topic,
@@ -30,13 +31,19 @@ type _channel[T any] struct {
 }
 
 func (c *_channel[T]) Pub(value T) {
+	// Sync and retain inside the router lock, like Pub,
+	// and call the handlers outside it.
+	c.r.mut.RLock()
 	c.sync()
+	c.r.retain(c.topic, value)
+	c.r.mut.RUnlock()
 	for _, fn := range c.handlers {
 		fn(c.topic, value)
 	}
-	c.r.retain(c.topic, value)
 }
 
+// sync rebuilds my handlers if the router has changed.
+// The router must be read locked.
 func (c *_channel[T]) sync() {
 	added := c.r.added.Load()
 	deleted := c.r.deleted.Load()
@@ -53,5 +60,5 @@ func (c *_channel[T]) sync() {
 			}
 		}
 	}
-	c.r.readVisit(c.topic, visitFn)
+	c.r.r.Visit(c.topic, visitFn)
 }
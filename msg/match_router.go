@@ -1,37 +1,39 @@
 package msg
 
+// MatchRouter stores data by pattern, and visits the patterns
+// that match a topic. Patterns are indexed in a topic trie, so
+// visiting only touches the levels a topic can reach. Match,
+// if set, is a final filter on the patterns the trie answers.
 type MatchRouter[T any] struct {
 	Init     initFunc[T]
 	Match    MatchFunc
-	patterns map[string]*matchEntry[T]
+	patterns topicTrie[*matchEntry[T]]
 }
 
 func (r *MatchRouter[T]) Edit(pattern string, fn editFunc[T]) {
-	r.validate()
-	gp, ok := r.patterns[pattern]
+	gp, ok := r.patterns.Get(pattern)
 	if !ok {
 		gp = &matchEntry[T]{}
 		if r.Init != nil {
 			r.Init(pattern, &gp.data)
 		}
-		r.patterns[pattern] = gp
+		r.patterns.Put(pattern, gp)
 	}
 	fn(0, &gp.data)
 }
 
 // Visit any glob patterns I contain that match the topic.
 func (r *MatchRouter[T]) Visit(topic string, fn visitFunc[T]) {
-	for k, v := range r.patterns {
-		if m := r.Match(k, topic); m {
-			fn(k, &v.data)
+	r.patterns.VisitPatterns(topic, func(pattern string, e *matchEntry[T]) {
+		if r.Match == nil || r.Match(pattern, topic) {
+			fn(pattern, &e.data)
 		}
-	}
+	})
 }
 
-func (r *MatchRouter[T]) validate() {
-	if r.patterns == nil {
-		r.patterns = make(map[string]*matchEntry[T])
-	}
+// Len answers the number of patterns.
+func (r *MatchRouter[T]) Len() int {
+	return r.patterns.Len()
 }
 
 type matchEntry[T any] struct {
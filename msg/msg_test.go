@@ -6,7 +6,10 @@ import (
 	"os"
 	"path"
 	"reflect"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 	"text/scanner"
 
@@ -108,6 +111,344 @@ func TestSequence(t *testing.T) {
 	f("sequences_2.txt")
 }
 
+// ---------------------------------------------------------
+// TEST-ASYNC
+
+func TestAsync(t *testing.T) {
+	f := func(opts AsyncOpts, pubs int, want []int) {
+		t.Helper()
+
+		r := NewRouter()
+		sub := newGatedSubscription()
+		SubAsync(r, "a", sub.receive, opts)
+		// The first message is held by the handler,
+		// so the rest fill the queue.
+		Pub(r, "a", 0)
+		<-sub.started
+		published := make(chan struct{})
+		go func() {
+			for i := 1; i < pubs; i++ {
+				Pub(r, "a", i)
+			}
+			close(published)
+		}()
+		if opts.Overflow != OverflowBlock {
+			<-published
+		}
+		close(sub.gate)
+		<-published
+		r.Close()
+
+		if reflect.DeepEqual(want, sub.captured) != true {
+			t.Fatalf("has \"%v\" but wants \"%v\"", sub.captured, want)
+		}
+	}
+	f(AsyncOpts{QueueSize: 2, Overflow: OverflowBlock}, 5, []int{0, 1, 2, 3, 4})
+	f(AsyncOpts{QueueSize: 2, Overflow: OverflowDropOldest}, 5, []int{0, 3, 4})
+	f(AsyncOpts{QueueSize: 2, Overflow: OverflowDropNewest}, 5, []int{0, 1, 2})
+	f(AsyncOpts{}, 100, newInts(100))
+}
+
+// ---------------------------------------------------------
+// TEST-ASYNC-CLOSE
+
+func TestAsyncClose(t *testing.T) {
+	r := NewRouter()
+	Pub(r, "a/a", 0)
+	var a, b []int
+	SubAsync(r, "a/#", func(topic string, v int) { a = append(a, v) }, AsyncOpts{})
+	unsub := SubAsync(r, "a/+", func(topic string, v int) { b = append(b, v) }, AsyncOpts{})
+	for i := 1; i < 10; i++ {
+		Pub(r, "a/a", i)
+	}
+	unsub.Unsub()
+	Pub(r, "a/a", 10)
+	r.Close()
+	// Messages after Close are dropped.
+	Pub(r, "a/a", 11)
+
+	if want := newInts(11); reflect.DeepEqual(want, a) != true {
+		t.Fatalf("has \"%v\" but wants \"%v\"", a, want)
+	}
+	if want := newInts(10); reflect.DeepEqual(want, b) != true {
+		t.Fatalf("has \"%v\" but wants \"%v\"", b, want)
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-UNSUB-IN-PROGRESS
+
+// TestUnsubInProgress pins that Unsub doesn't stop a publish
+// that has already collected the handler.
+func TestUnsubInProgress(t *testing.T) {
+	r := NewRouter()
+	var sub Subscription
+	var captured []int
+	// "+" is visited before "a", so the first handler
+	// unsubscribes the second while the publish is running.
+	Sub(r, "+", func(topic string, v int) {
+		if v == 1 {
+			sub.Unsub()
+		}
+	})
+	sub = Sub(r, "a", func(topic string, v int) {
+		captured = append(captured, v)
+	})
+	Pub(r, "a", 1)
+	Pub(r, "a", 2)
+
+	if want := []int{1}; reflect.DeepEqual(want, captured) != true {
+		t.Fatalf("has \"%v\" but wants \"%v\"", captured, want)
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-CONCURRENT
+
+// TestConcurrent publishes and subscribes from many goroutines,
+// and checks that every subscription receives an unbroken run
+// of each topic, ending at the last message. Run with -race.
+func TestConcurrent(t *testing.T) {
+	const publishers, subscribers, messages = 8, 16, 200
+	f := func(async bool) {
+		t.Helper()
+
+		r := NewRouter()
+		subs := make([]*concurrentSubscription, subscribers)
+		start := make(chan struct{})
+		wg := sync.WaitGroup{}
+		for i := 0; i < publishers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				<-start
+				topic := fmt.Sprintf("p/%v", i)
+				c := NewChannel[int](r, topic)
+				for m := 0; m < messages; m++ {
+					if m%2 == 0 {
+						Pub(r, topic, m)
+					} else {
+						c.Pub(m)
+					}
+				}
+			}(i)
+		}
+		for i := 0; i < subscribers; i++ {
+			subs[i] = &concurrentSubscription{captured: make(map[string][]int)}
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				<-start
+				// Stagger subscribing throughout the publishing.
+				for n := 0; n < i*10; n++ {
+					runtime.Gosched()
+				}
+				if async {
+					SubAsync(r, "p/+", subs[i].receive, AsyncOpts{QueueSize: 4})
+				} else {
+					Sub(r, "p/+", subs[i].receive)
+				}
+			}(i)
+		}
+		// Churn subscriptions alongside the tested ones.
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			for n := 0; n < messages; n++ {
+				Sub(r, "p/#", func(string, int) {}).Unsub()
+			}
+		}()
+		close(start)
+		wg.Wait()
+		r.Close()
+
+		for i, sub := range subs {
+			if len(sub.captured) != publishers {
+				t.Fatalf("async %v subscriber %v has %v topics but wants %v", async, i, len(sub.captured), publishers)
+			}
+			for topic, have := range sub.captured {
+				want := newInts(messages)[have[0]:]
+				if reflect.DeepEqual(want, have) != true {
+					t.Fatalf("async %v subscriber %v topic %v has \"%v\" but wants \"%v\"", async, i, topic, have, want)
+				}
+			}
+		}
+	}
+	f(false)
+	f(true)
+}
+
+// ---------------------------------------------------------
+// TEST-MATCH-ROUTER
+
+func TestMatchRouter(t *testing.T) {
+	f := func(patterns []string, topic string, want []string) {
+		t.Helper()
+
+		r := newTestMatchRouter(patterns)
+		have := []string{}
+		r.Visit(topic, func(pattern string, data *int) {
+			have = append(have, pattern)
+		})
+		slices.Sort(have)
+		if reflect.DeepEqual(want, have) != true {
+			t.Fatalf("topic %v has \"%v\" but wants \"%v\"", topic, have, want)
+		}
+	}
+	patterns := []string{"a", "a/b", "a/+", "a/#", "+/b", "+", "#", "a/+/c", "a/b/c", "/a/b", "b/#/c"}
+	f(patterns, "a", []string{"#", "+", "a", "a/#", "a/+"})
+	f(patterns, "a/b", []string{"#", "+/b", "/a/b", "a/#", "a/+", "a/b"})
+	f(patterns, "a/b/c", []string{"#", "a/#", "a/+/c", "a/b/c"})
+	f(patterns, "b/x/y", []string{"#", "b/#/c"})
+	f(patterns, "c/d", []string{"#"})
+	f(nil, "a", []string{})
+}
+
+// ---------------------------------------------------------
+// TEST-MATCH-ROUTER-MQTT
+
+// TestMatchRouterMqtt checks the trie against the
+// linear scan for every pattern and topic pair.
+func TestMatchRouterMqtt(t *testing.T) {
+	patterns := []string{"", "a", "a/", "/a", "a//b", "a/b", "a/+", "a/#", "+", "#", "+/+", "+/#", "a/+/c", "a/#/c", "b", "b/+"}
+	topics := []string{"", "/", "a", "a/", "/a", "a/b", "a//b", "a/b/", "a/b/c", "b", "b/c", "c/a/b"}
+	r := newTestMatchRouter(patterns)
+	for _, topic := range topics {
+		have := []string{}
+		r.Visit(topic, func(pattern string, data *int) {
+			have = append(have, pattern)
+		})
+		want := linearVisit(patterns, topic)
+		slices.Sort(have)
+		if reflect.DeepEqual(want, have) != true {
+			t.Fatalf("topic \"%v\" has \"%v\" but wants \"%v\"", topic, have, want)
+		}
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-RETAINED
+
+func TestRetained(t *testing.T) {
+	f := func(topics []string, pattern string, want []string) {
+		t.Helper()
+
+		r := newRetained(mqttMatch)
+		for _, topic := range topics {
+			r.Retain(topic, topic)
+		}
+		have := []string{}
+		r.Visit(pattern, func(topic string, last any) {
+			have = append(have, last.(string))
+		})
+		if reflect.DeepEqual(want, have) != true {
+			t.Fatalf("pattern %v has \"%v\" but wants \"%v\"", pattern, have, want)
+		}
+	}
+	topics := []string{"a", "a/b", "a/c", "a/b/c", "b/c", "/a/b"}
+	f(topics, "a", []string{"a"})
+	f(topics, "a/b", []string{"a/b", "/a/b"})
+	f(topics, "a/+", []string{"a", "a/b", "/a/b", "a/c"})
+	f(topics, "+/c", []string{"a/c", "b/c"})
+	f(topics, "a/#", []string{"a", "a/b", "/a/b", "a/b/c", "a/c"})
+	f(topics, "#", []string{"a", "a/b", "/a/b", "a/b/c", "a/c", "b/c"})
+	f(topics, "c", []string{})
+}
+
+// ---------------------------------------------------------
+// BENCHMARKS
+
+func BenchmarkMatchRouter(b *testing.B) {
+	for _, size := range []int{10, 1000, 100000} {
+		patterns := newBenchmarkPatterns(size)
+		topic := fmt.Sprintf("ui/%v/value", size/2)
+		b.Run(fmt.Sprintf("trie-%v", size), func(b *testing.B) {
+			r := newTestMatchRouter(patterns)
+			visit := func(pattern string, data *int) {}
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				r.Visit(topic, visit)
+			}
+		})
+		b.Run(fmt.Sprintf("linear-%v", size), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				for _, p := range patterns {
+					mqttMatch(p, topic)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRetained(b *testing.B) {
+	for _, size := range []int{10, 1000, 100000} {
+		topics := newBenchmarkPatterns(size)
+		pattern := fmt.Sprintf("ui/%v/+", size/2)
+		b.Run(fmt.Sprintf("trie-%v", size), func(b *testing.B) {
+			r := newRetained(mqttMatch)
+			for _, topic := range topics {
+				r.Retain(topic, 1)
+			}
+			visit := func(topic string, last any) {}
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				r.Visit(pattern, visit)
+			}
+		})
+		b.Run(fmt.Sprintf("linear-%v", size), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				for _, topic := range topics {
+					mqttMatch(pattern, topic)
+				}
+			}
+		})
+	}
+}
+
+// ---------------------------------------------------------
+// MATCH SUPPORT
+
+func newTestMatchRouter(patterns []string) *MatchRouter[int] {
+	r := &MatchRouter[int]{Match: mqttMatch}
+	for i, p := range patterns {
+		r.Edit(p, func(n int64, data *int) {
+			*data = i
+		})
+	}
+	return r
+}
+
+// linearVisit answers the sorted patterns that match
+// the topic, the way MatchRouter used to scan them.
+func linearVisit(patterns []string, topic string) []string {
+	matches := []string{}
+	for _, p := range patterns {
+		if mqttMatch(p, topic) {
+			matches = append(matches, p)
+		}
+	}
+	slices.Sort(matches)
+	return matches
+}
+
+// newBenchmarkPatterns answers size patterns, mostly
+// literal with some single and multi level wildcards.
+func newBenchmarkPatterns(size int) []string {
+	patterns := make([]string, 0, size)
+	for i := 0; i < size; i++ {
+		switch i % 10 {
+		case 0:
+			patterns = append(patterns, fmt.Sprintf("ui/%v/+", i))
+		case 1:
+			patterns = append(patterns, fmt.Sprintf("ui/%v/#", i))
+		default:
+			patterns = append(patterns, fmt.Sprintf("ui/%v/value", i))
+		}
+	}
+	return patterns
+}
+
 // ---------------------------------------------------------
 // SEQUENCE SUPPORT
 // A whole bunch of cruft to handle creating test sequences
@@ -409,6 +750,45 @@ func (s *captureSubscription) receiveNamer(topic string, value Namer) {
 	s.namerCaptured = append(s.namerCaptured, value)
 }
 
+// concurrentSubscription captures the values of each topic.
+type concurrentSubscription struct {
+	mut      sync.Mutex
+	captured map[string][]int
+}
+
+func (s *concurrentSubscription) receive(topic string, value int) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.captured[topic] = append(s.captured[topic], value)
+}
+
+// gatedSubscription holds the first message until the gate is closed.
+type gatedSubscription struct {
+	started  chan struct{}
+	gate     chan struct{}
+	captured []int
+}
+
+func newGatedSubscription() *gatedSubscription {
+	return &gatedSubscription{started: make(chan struct{}), gate: make(chan struct{})}
+}
+
+func (s *gatedSubscription) receive(topic string, value int) {
+	if len(s.captured) < 1 {
+		close(s.started)
+		<-s.gate
+	}
+	s.captured = append(s.captured, value)
+}
+
+func newInts(n int) []int {
+	ints := make([]int, n)
+	for i := range ints {
+		ints[i] = i
+	}
+	return ints
+}
+
 // ---------------------------------------------------------
 // FUNC
 
@@ -2,16 +2,21 @@ package msg
 
 import (
 	"sync/atomic"
+
+	"github.com/hackborn/onefunc/sync"
 )
 
 func newRetained(match MatchFunc) *retained {
-	all := make(map[string]last)
-	return &retained{all: all, match: match}
+	return &retained{match: match}
 }
 
+// retained stores the last value published to each topic.
+// It is safe for concurrent use.
 type retained struct {
-	// all is a map of topic to last published value.
-	all   map[string]last
+	mut sync.RWMutex
+	// all is a trie of topic to last published value.
+	all topicTrie[*last]
+	// match, if set, is a final filter on the topics the trie answers.
 	match MatchFunc
 }
 
@@ -21,19 +26,26 @@ func (r *retained) Retain(topic string, value any) {
 	if value == nil {
 		return
 	}
-	last := last{}
-	last.value.Store(value)
-	r.all[topic] = last
+	// Replace rather than Store, since atomic.Value
+	// panics if a topic changes type.
+	l := &last{}
+	l.value.Store(value)
+	defer sync.Write(&r.mut).Unlock()
+	r.all.Put(topic, l)
 }
 
+// Visit the retained values that match the pattern. The
+// store is locked during the visit, so fn must not call Retain.
 func (r *retained) Visit(pattern string, fn retainedVisitFunc) {
-	for k, last := range r.all {
-		if r.match(pattern, k) {
-			if v := last.value.Load(); v != nil {
-				fn(k, v)
-			}
+	defer sync.Read(&r.mut).Unlock()
+	r.all.VisitTopics(pattern, func(topic string, l *last) {
+		if r.match != nil && !r.match(pattern, topic) {
+			return
 		}
-	}
+		if v := l.value.Load(); v != nil {
+			fn(topic, v)
+		}
+	})
 }
 
 type last struct {
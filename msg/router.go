@@ -19,32 +19,63 @@ func NewRouter() *Router {
 // Router provides message routing. Never instantiate it directly,
 // use NewRouter() instead, which performs setup.
 //
-// The current implementation is not designed to be highly optimized.
-// The main optimization provided is the Channel, for clients that
+// Subscriptions and retained values are indexed in topic tries, so
+// publishing and subscribing cost the levels a topic can reach rather
+// than the number of patterns. The other optimization provided is the Channel, for clients that
 // will repeatedly publish to the same topic. A Channel is pretty
 // much just a straight function call on all handlers, so if subscription
 // changes are minimal and everyone uses Channels then performance should
 // be very good, but if you need frequent changes to subscriptions then
 // performance will degrade.
 //
-// The current implementation is curiously partially-thread safe.
-// The router itself is safe, but clients would need to correctly
-// handle thread safety if they actually sent/handled messages across
-// threads (and if you did that, you would want to use a chan). So
-// in practice this should only be used in a single thread, and I might
-// remove the locking at a future point.
+// The router, including retained values, is safe for concurrent use.
+// Handlers run synchronously inside Pub, on the publishing goroutine,
+// so a handler subscribed with Sub must be safe to call from every
+// goroutine that publishes to it. To receive messages from other
+// threads subscribe with SubAsync, which delivers on a dedicated
+// goroutine. Call Close() to stop async subscriptions.
+//
+// Ordering guarantees:
+//   - A subscription receives every message published after Sub
+//     returns, and the retained value of each matching topic at the
+//     moment it subscribed. A message published concurrently with Sub
+//     is delivered exactly once, either live or replayed, unless an
+//     async queue drops it.
+//   - Messages published from one goroutine are delivered to each
+//     subscription in the order they were published.
+//   - An async subscription receives its replayed messages before
+//     any live message, and live messages in the order they were queued.
+//   - A sync subscription receives its replayed messages before any
+//     live message. Live messages that arrive during the replay are
+//     held and delivered by Sub, in arrival order, once it's done.
+//   - Messages from different goroutines have no relative order.
+//   - The retained value of a topic is from the last publish to
+//     enter the router, which may not be the last one delivered.
+//   - Unsub stops later publishes from reaching the subscription, but
+//     not ones already in progress: handlers are collected under the
+//     lock and called after it's released, so a sync handler can run
+//     after its Unsub returns, and an async subscription delivers its
+//     queued messages. Clients that tear down state on Unsub must
+//     tolerate a late delivery.
 type Router struct {
 	added    atomic.Int64
 	deleted  atomic.Int64
 	mut      sync.RWMutex
 	r        MatchRouter[routerSubscriptions]
 	retained *retained
+
+	// Async subscription queues, see SubAsync.
+	queueMut sync.Mutex
+	queues   map[queue]struct{}
+	closed   bool
 }
 
 // Subscribe to the handlerfunc. Note that clients can
 // not call this function directly, they must go through Sub
-// so that the function is properly recognized.
-func (r *Router) sub(pattern string, value any) Subscription {
+// so that the function is properly recognized. The retained
+// messages are visited inside the same lock, so no publish
+// can land between adding the handler and the replay.
+func (r *Router) sub(pattern string, value any, retainFn retainedVisitFunc) Subscription {
 	var sub Subscription
 	fn := func(n int64, subs *routerSubscriptions) {
 		r.added.Add(1)
@@ -52,6 +83,7 @@ func (r *Router) sub(pattern string, value any) Subscription {
 	}
 	defer sync.Write(&r.mut).Unlock()
 	r.r.Edit(pattern, fn)
+	r.visitRetained(pattern, retainFn)
 	return sub
 }
 
@@ -60,7 +92,7 @@ func (r *Router) unsub(pattern string, id int64) {
 		subs.remove(id)
 	}
 	defer sync.Write(&r.mut).Unlock()
-	if len(r.r.patterns) < 1 {
+	if r.r.Len() < 1 {
 		return
 	}
 	r.r.Edit(pattern, fn)
@@ -78,9 +110,14 @@ func (r *Router) visitRetained(pattern string, fn retainedVisitFunc) {
 	}
 }
 
-func (r *Router) readVisit(topic string, fn visitFunc[routerSubscriptions]) {
+// publish visits the subscriptions for the topic and retains
+// the value inside the same lock, so it's atomic with respect
+// to sub. Publishes only hold the read lock, so they can run
+// concurrently, with the retained store serializing its writes.
+func (r *Router) publish(topic string, value any, fn visitFunc[routerSubscriptions]) {
 	defer sync.Read(&r.mut).Unlock()
 	r.r.Visit(topic, fn)
+	r.retain(topic, value)
 }
 
 func (r *Router) subsInit(pattern string, subs *routerSubscriptions) {
@@ -5,6 +5,8 @@ import (
 )
 
 type Subscription interface {
+	// Unsub stops delivery of later publishes. A publish
+	// already in progress can still deliver, see Router.
 	Unsub()
 }
 
@@ -18,6 +20,12 @@ func (s *subscription) Unsub() {
 	s.r.unsub(s.pattern, s.id)
 }
 
+// message is a topic and value waiting to be delivered.
+type message[T any] struct {
+	topic string
+	value T
+}
+
 type routerSubscriptions struct {
 	r       *Router
 	pattern string
@@ -0,0 +1,186 @@
+package msg
+
+// topicTrie indexes values by their topic or pattern, one
+// level per node. Levels follow MQTT rules: they are separated
+// by "/", empty levels are ignored, and "+" and "#" are the
+// single and multi level wildcards.
+//
+// Matching is a superset of MqttMatch, which differs only for
+// topics with trailing or repeated separators, so clients that
+// need exact MQTT results should filter with it.
+type topicTrie[V any] struct {
+	root trieNode[V]
+	size int
+}
+
+type trieNode[V any] struct {
+	// entries are the values that end at this node. Keys
+	// that only differ by separators share a node, so
+	// there can be more than one.
+	entries  []trieEntry[V]
+	children map[string]*trieNode[V]
+	// order is the children in insertion order, so
+	// visits are deterministic.
+	order []*trieNode[V]
+}
+
+type trieEntry[V any] struct {
+	// key is the full topic or pattern of the value.
+	key   string
+	value V
+}
+
+// Get answers the value for the key, if it exists.
+func (t *topicTrie[V]) Get(key string) (V, bool) {
+	n := &t.root
+	for level, next := nextLevel(key); level != ""; level, next = nextLevel(next) {
+		if n = n.children[level]; n == nil {
+			var v V
+			return v, false
+		}
+	}
+	for _, e := range n.entries {
+		if e.key == key {
+			return e.value, true
+		}
+	}
+	var v V
+	return v, false
+}
+
+// Put sets the value for the key.
+func (t *topicTrie[V]) Put(key string, value V) {
+	n := &t.root
+	for level, next := nextLevel(key); level != ""; level, next = nextLevel(next) {
+		n = n.child(level)
+	}
+	for i, e := range n.entries {
+		if e.key == key {
+			n.entries[i].value = value
+			return
+		}
+	}
+	n.entries = append(n.entries, trieEntry[V]{key: key, value: value})
+	t.size++
+}
+
+// Len answers the number of values.
+func (t *topicTrie[V]) Len() int {
+	return t.size
+}
+
+// VisitPatterns visits every value whose key is a
+// pattern that matches the topic.
+func (t *topicTrie[V]) VisitPatterns(topic string, fn func(key string, value V)) {
+	t.root.visitPatterns(topic, fn)
+}
+
+// VisitTopics visits every value whose key is a
+// topic that matches the pattern.
+func (t *topicTrie[V]) VisitTopics(pattern string, fn func(key string, value V)) {
+	t.root.visitTopics(pattern, fn)
+}
+
+func (n *trieNode[V]) child(level string) *trieNode[V] {
+	if c, ok := n.children[level]; ok {
+		return c
+	}
+	if n.children == nil {
+		n.children = make(map[string]*trieNode[V])
+	}
+	c := &trieNode[V]{}
+	n.children[level] = c
+	n.order = append(n.order, c)
+	return c
+}
+
+// visitPatterns visits the patterns below me that match
+// the remainder of the topic.
+func (n *trieNode[V]) visitPatterns(topic string, fn func(string, V)) {
+	level, next := nextLevel(topic)
+	// "+" matches an empty level, so patterns here
+	// match an exhausted topic whether or not I was
+	// reached through a wildcard.
+	if level == "" {
+		n.visitEntries(fn)
+	}
+	if c, ok := n.children["#"]; ok {
+		c.visitAll(fn)
+	}
+	if c, ok := n.children["+"]; ok {
+		c.visitPatterns(next, fn)
+	}
+	if level == "" || level == "+" || level == "#" {
+		return
+	}
+	if c, ok := n.children[level]; ok {
+		c.visitPatterns(next, fn)
+	}
+}
+
+// visitTopics visits the topics below me that match
+// the remainder of the pattern.
+func (n *trieNode[V]) visitTopics(pattern string, fn func(string, V)) {
+	level, next := nextLevel(pattern)
+	switch level {
+	case "":
+		n.visitEntries(fn)
+	case "#":
+		n.visitAll(fn)
+	case "+":
+		if matchesEmpty(next) {
+			n.visitEntries(fn)
+		}
+		for _, c := range n.order {
+			c.visitTopics(next, fn)
+		}
+	default:
+		if c, ok := n.children[level]; ok {
+			c.visitTopics(next, fn)
+		}
+	}
+}
+
+// visitAll visits me and every value below me.
+func (n *trieNode[V]) visitAll(fn func(string, V)) {
+	n.visitEntries(fn)
+	for _, c := range n.order {
+		c.visitAll(fn)
+	}
+}
+
+func (n *trieNode[V]) visitEntries(fn func(string, V)) {
+	for _, e := range n.entries {
+		fn(e.key, e.value)
+	}
+}
+
+// nextLevel answers the first level of s and the remainder,
+// skipping empty levels. The level is empty when s is exhausted.
+func nextLevel(s string) (string, string) {
+	for len(s) > 0 && s[0] == '/' {
+		s = s[1:]
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '/' {
+			return s[:i], s[i+1:]
+		}
+	}
+	return s, ""
+}
+
+// matchesEmpty answers true if the pattern matches
+// an exhausted topic, i.e. it has only "+" levels
+// before the end or a "#".
+func matchesEmpty(pattern string) bool {
+	for level, next := nextLevel(pattern); level != ""; level, next = nextLevel(next) {
+		switch level {
+		case "#":
+			return true
+		case "+":
+		default:
+			return false
+		}
+	}
+	return true
+}
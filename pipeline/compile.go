@@ -18,25 +18,13 @@ func Compile(expr string) (*Pipeline, error) {
 	nodes := make(map[string]*compiledNode)
 	roots := make(map[string]compileRoot)
 	for i, nn := range ast.nodes {
-		splitName := strings.Split(nn.nodeName, "/")
-		node, err := newNode(splitName[0])
+		rn, err := compileNode(nn.nodeName, nn.vars, nn.envVars)
 		if err != nil {
 			return nil, err
 		}
-		rn := &compiledNode{node: node, envVars: nn.envVars}
-		rn.flusher, _ = node.(Flusher)
-		rn.starter, _ = node.(Starter)
 		nodes[nn.nodeName] = rn
 		roots[nn.nodeName] = compileRoot{index: i, node: rn}
 		pipeline.nodes = append(pipeline.nodes, rn)
-		// apply fixed vars
-		if len(nn.vars) > 0 {
-			req := reflect.SetRequestFrom(nn.vars)
-			err = reflect.Set(req, rn.node)
-			if err != nil {
-				return nil, err
-			}
-		}
 	}
 	for _, pin := range ast.pins {
 		fromNode, _ := nodes[pin.fromNode]
@@ -59,6 +47,28 @@ func Compile(expr string) (*Pipeline, error) {
 	return pipeline, nil
 }
 
+// compileNode instantiates the registered node for name
+// and applies the fixed vars.
+func compileNode(name string, vars map[string]any, envVars map[string]string) (*compiledNode, error) {
+	splitName := strings.Split(name, "/")
+	node, err := newNode(splitName[0])
+	if err != nil {
+		return nil, err
+	}
+	cn := &compiledNode{name: name, node: node, vars: vars, envVars: envVars}
+	cn.flusher, _ = node.(Flusher)
+	cn.starter, _ = node.(Starter)
+	// apply fixed vars
+	if len(vars) > 0 {
+		req := reflect.SetRequestFrom(vars)
+		err = reflect.Set(req, cn.node)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
 func compileRoots(mapRoots map[string]compileRoot) []*compiledNode {
 	// Keep the roots in the same order as the AST nodes. Not
 	// strictly necessary, but it does make tests predictable.
@@ -0,0 +1,67 @@
+package pipeline
+
+import (
+	"github.com/hackborn/onefunc/sync"
+)
+
+// NewCow answers a copy-on-write wrapper around payload.
+func NewCow(payload Cloner) *Cow {
+	return &Cow{shared: &cowShared{value: payload, refs: 1}}
+}
+
+// Cow is a copy-on-write payload wrapper. Cloning a Cow is
+// cheap: the clone shares the wrapped value with the original,
+// so a payload fanned out to many nodes is only copied by the
+// nodes that actually modify it.
+//
+// Readers access the shared value with Get() (or Unwrap()) and
+// must treat it as read-only. A node that wants to modify the
+// value must request a private copy with State.Mutable().
+type Cow struct {
+	shared *cowShared
+}
+
+type cowShared struct {
+	lock  sync.Mutex
+	value Cloner
+	refs  int
+}
+
+// Clone answers a new Cow sharing my value.
+func (c *Cow) Clone() Cloner {
+	defer sync.Lock(&c.shared.lock).Unlock()
+	c.shared.refs++
+	return &Cow{shared: c.shared}
+}
+
+// Get answers the shared value. It must not be modified.
+func (c *Cow) Get() Cloner {
+	return c.shared.value
+}
+
+// take answers a value the caller owns. If I am the last
+// reference to the shared value it is handed over directly,
+// otherwise a copy is made. Either way I become the sole
+// owner of the answer, so repeated calls are harmless.
+func (c *Cow) take() Cloner {
+	sh := c.shared
+	defer sync.Lock(&sh.lock).Unlock()
+	if sh.refs <= 1 || sh.value == nil {
+		return sh.value
+	}
+	sh.refs--
+	value := sh.value.Clone()
+	c.shared = &cowShared{value: value, refs: 1}
+	return value
+}
+
+// Unwrap answers the value wrapped by a Cow, or the payload
+// itself for any other type. Use this when reading a payload
+// that might be wrapped, i.e.
+// switch p := pipeline.Unwrap(pin.Payload).(type)
+func Unwrap(payload Cloner) Cloner {
+	if c, ok := payload.(*Cow); ok {
+		return c.Get()
+	}
+	return payload
+}
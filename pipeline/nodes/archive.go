@@ -0,0 +1,335 @@
+package nodes
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	oferrors "github.com/hackborn/onefunc/errors"
+	"github.com/hackborn/onefunc/pipeline"
+)
+
+// LoadArchiveNode reads the entries of zip, tar and tar.gz
+// archives, producing a ContentData for each entry. Archives
+// are loaded from files matching the Glob, and from any incoming
+// ContentData named as an archive (such as the output of an
+// ArchiveNode). All other pins are passed through.
+type LoadArchiveNode struct {
+	loadArchiveData
+}
+
+type loadArchiveData struct {
+	// Fs, Glob and Separator select the archive files,
+	// see LoadFileNode. If Glob is empty, only incoming
+	// pins are read.
+	loadFileData
+
+	// Optional glob pattern used to select which entries are
+	// loaded. Entry names are slash-separated paths within the
+	// archive, see path.Match() for match rules.
+	Entry string
+
+	// Optional archive format ("zip", "tar" or "tar.gz").
+	// By default the format is determined by the file extension.
+	Format string
+}
+
+func (n *LoadArchiveNode) Start(input pipeline.StartInput) error {
+	data := n.loadArchiveData
+	input.SetNodeData(&data)
+	return nil
+}
+
+func (n *LoadArchiveNode) Run(state *pipeline.State, input pipeline.RunInput, output *pipeline.RunOutput) error {
+	data := state.NodeData.(*loadArchiveData)
+	if _, err := path.Match(data.Entry, ""); err != nil {
+		return fmt.Errorf("LoadArchiveNode: bad entry pattern \"%v\": %w", data.Entry, err)
+	}
+
+	eb := &oferrors.FirstBlock{}
+	for _, pin := range input.Pins {
+		cd, ok := pipeline.Unwrap(pin.Payload).(*pipeline.ContentData)
+		if !ok {
+			output.Pins = append(output.Pins, pin)
+			continue
+		}
+		format, err := archiveFormat(cd.Name, cmp.Or(data.Format, cd.Format))
+		if err != nil {
+			output.Pins = append(output.Pins, pin)
+			continue
+		}
+		eb.AddError(n.readArchive(data, format, []byte(cd.Data), output))
+	}
+
+	if data.Glob != "" {
+		ln := &LoadFileNode{}
+		get, read, err := ln.prepare(&data.loadFileData)
+		if err != nil {
+			return err
+		}
+		matches, err := ln.getMatches(data.Glob, data.Separator, get)
+		eb.AddError(err)
+		for _, fn := range matches {
+			format, err := archiveFormat(fn, data.Format)
+			if err != nil {
+				eb.AddError(err)
+				continue
+			}
+			dat, err := read(fn)
+			if err != nil {
+				eb.AddError(err)
+				continue
+			}
+			eb.AddError(n.readArchive(data, format, dat, output))
+		}
+	}
+	return eb.Err
+}
+
+func (n *LoadArchiveNode) readArchive(data *loadArchiveData, format string, dat []byte, output *pipeline.RunOutput) error {
+	return readArchive(format, dat, func(name string, entry []byte) error {
+		if data.Entry != "" {
+			if ok, _ := path.Match(data.Entry, name); !ok {
+				return nil
+			}
+		}
+		output.Pins = append(output.Pins, pipeline.Pin{Payload: &pipeline.ContentData{Name: name, Data: string(entry)}})
+		return nil
+	})
+}
+
+// ArchiveNode packs all incoming ContentData into a single
+// archive. The archive is sent out as a ContentData and, if
+// Path is set, saved to a file. All other pins are passed through.
+type ArchiveNode struct {
+	archiveData
+}
+
+type archiveData struct {
+	// Name of the archive, i.e. "bundle.zip".
+	Name string
+
+	// Optional archive format ("zip", "tar" or "tar.gz").
+	// By default the format is determined by the Name extension.
+	Format string
+
+	// Optional folder to save the archive to.
+	Path string
+
+	content []*pipeline.ContentData
+}
+
+func (n *ArchiveNode) Start(input pipeline.StartInput) error {
+	data := n.archiveData
+	input.SetNodeData(&data)
+	return nil
+}
+
+func (n *ArchiveNode) Run(state *pipeline.State, input pipeline.RunInput, output *pipeline.RunOutput) error {
+	data := state.NodeData.(*archiveData)
+	for _, pin := range input.Pins {
+		if cd, ok := pipeline.Unwrap(pin.Payload).(*pipeline.ContentData); ok {
+			data.content = append(data.content, cd)
+		} else {
+			output.Pins = append(output.Pins, pin)
+		}
+	}
+	return nil
+}
+
+func (n *ArchiveNode) Flush(state *pipeline.State, output *pipeline.RunOutput) error {
+	data := state.NodeData.(*archiveData)
+	if data.Name == "" {
+		return fmt.Errorf("ArchiveNode: no name")
+	}
+	format, err := archiveFormat(data.Name, data.Format)
+	if err != nil {
+		return err
+	}
+	dat, err := writeArchive(format, data.content)
+	if err != nil {
+		return err
+	}
+	if data.Path != "" {
+		if !filepath.IsLocal(filepath.FromSlash(data.Name)) {
+			return fmt.Errorf("ArchiveNode: name \"%v\" escapes the path", data.Name)
+		}
+		dir := filepath.FromSlash(data.Path)
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			return fmt.Errorf("ArchiveNode: path \"%v\" does not exist", dir)
+		}
+		if err = os.WriteFile(filepath.Join(dir, data.Name), dat, 0644); err != nil {
+			return err
+		}
+	}
+	output.Pins = append(output.Pins, pipeline.Pin{Payload: &pipeline.ContentData{Name: data.Name, Data: string(dat), Format: format}})
+	return nil
+}
+
+const (
+	zipFormat   = "zip"
+	tarFormat   = "tar"
+	tarGzFormat = "tar.gz"
+)
+
+// archiveFormat answers the format, or the format based
+// on the name's extension if format is empty.
+func archiveFormat(name, format string) (string, error) {
+	format = strings.ToLower(format)
+	if format == "" {
+		name = strings.ToLower(name)
+		switch {
+		case strings.HasSuffix(name, ".zip"):
+			format = zipFormat
+		case strings.HasSuffix(name, ".tar"):
+			format = tarFormat
+		case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+			format = tarGzFormat
+		}
+	}
+	switch format {
+	case zipFormat, tarFormat, tarGzFormat:
+		return format, nil
+	case "tgz":
+		return tarGzFormat, nil
+	}
+	return "", fmt.Errorf("unknown archive format for \"%v\"", name)
+}
+
+// readArchive calls fn on each file entry in the archive.
+func readArchive(format string, dat []byte, fn func(name string, entry []byte) error) error {
+	switch format {
+	case zipFormat:
+		zr, err := zip.NewReader(bytes.NewReader(dat), int64(len(dat)))
+		if err != nil {
+			return err
+		}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			entry, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+			name, err := entryName(f.Name)
+			if err != nil {
+				return err
+			}
+			if err = fn(name, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	case tarGzFormat:
+		gr, err := gzip.NewReader(bytes.NewReader(dat))
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		return readTar(gr, fn)
+	case tarFormat:
+		return readTar(bytes.NewReader(dat), fn)
+	}
+	return fmt.Errorf("unknown archive format \"%v\"", format)
+}
+
+func readTar(r io.Reader, fn func(name string, entry []byte) error) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		entry, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		name, err := entryName(hdr.Name)
+		if err != nil {
+			return err
+		}
+		if err = fn(name, entry); err != nil {
+			return err
+		}
+	}
+}
+
+// entryName answers the cleaned entry name. Names that are
+// absolute or escape the archive root are rejected, since
+// clients join them to a folder when saving (zip slip).
+func entryName(name string) (string, error) {
+	clean := path.Clean(name)
+	if path.IsAbs(clean) || !filepath.IsLocal(filepath.FromSlash(clean)) {
+		return "", fmt.Errorf("archive entry \"%v\" escapes the archive root", name)
+	}
+	return clean, nil
+}
+
+// writeArchive answers an archive containing the content.
+func writeArchive(format string, content []*pipeline.ContentData) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	switch format {
+	case zipFormat:
+		zw := zip.NewWriter(buf)
+		for _, cd := range content {
+			w, err := zw.Create(cd.Name)
+			if err != nil {
+				return nil, err
+			}
+			if _, err = io.WriteString(w, cd.Data); err != nil {
+				return nil, err
+			}
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+	case tarGzFormat:
+		gw := gzip.NewWriter(buf)
+		if err := writeTar(gw, content); err != nil {
+			return nil, err
+		}
+		if err := gw.Close(); err != nil {
+			return nil, err
+		}
+	case tarFormat:
+		if err := writeTar(buf, content); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown archive format \"%v\"", format)
+	}
+	return buf.Bytes(), nil
+}
+
+func writeTar(w io.Writer, content []*pipeline.ContentData) error {
+	tw := tar.NewWriter(w)
+	for _, cd := range content {
+		hdr := &tar.Header{Name: cd.Name, Mode: 0644, Size: int64(len(cd.Data)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(tw, cd.Data); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
@@ -0,0 +1,341 @@
+package nodes
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	oferrors "github.com/hackborn/onefunc/errors"
+	"github.com/hackborn/onefunc/pipeline"
+)
+
+// DecodeNode converts ContentData in a structured format
+// into TreeData. All other pins are passed through.
+//
+// Formats:
+// "json" -- decoded with encoding/json.
+// "csv" -- a slice of rows, each row a map of the header
+// (first record) to the row values.
+// "xml" -- each element is a map of its children. Attributes
+// are stored with an "@" prefix and text as "#text"; elements
+// with only text are stored as a string, and repeated elements
+// as a slice.
+type DecodeNode struct {
+	decodeData
+}
+
+type decodeData struct {
+	// Optional format. By default the format is determined by
+	// the ContentData.Format, then the ContentData.Name extension.
+	// ContentData that doesn't resolve to a format is passed through.
+	Format string
+}
+
+func (n *DecodeNode) Start(input pipeline.StartInput) error {
+	data := n.decodeData
+	input.SetNodeData(&data)
+	return nil
+}
+
+func (n *DecodeNode) Run(state *pipeline.State, input pipeline.RunInput, output *pipeline.RunOutput) error {
+	data := state.NodeData.(*decodeData)
+	eb := &oferrors.FirstBlock{}
+	for _, pin := range input.Pins {
+		cd, ok := pipeline.Unwrap(pin.Payload).(*pipeline.ContentData)
+		if !ok {
+			output.Pins = append(output.Pins, pin)
+			continue
+		}
+		format := codecFormat(cd.Name, cmp.Or(data.Format, cd.Format))
+		c, ok := codecs[format]
+		if !ok {
+			output.Pins = append(output.Pins, pin)
+			continue
+		}
+		v, err := c.decode([]byte(cd.Data))
+		if err != nil {
+			eb.AddError(fmt.Errorf("DecodeNode: %v: %w", cd.Name, err))
+			continue
+		}
+		td := &pipeline.TreeData{Name: cd.Name, Value: v, Format: format}
+		output.Pins = append(output.Pins, pipeline.Pin{Name: pin.Name, Payload: td, Policy: pin.Policy})
+	}
+	return eb.Err
+}
+
+// EncodeNode converts TreeData into ContentData.
+// All other pins are passed through. See DecodeNode for
+// a description of the formats.
+type EncodeNode struct {
+	encodeData
+}
+
+type encodeData struct {
+	// Optional format. By default the TreeData.Format is used.
+	Format string
+
+	// Optional indent for JSON and XML output.
+	Indent string
+
+	// Optional comma-separated list of CSV columns. By default
+	// all keys are written in sorted order.
+	Columns string
+}
+
+func (n *EncodeNode) Start(input pipeline.StartInput) error {
+	data := n.encodeData
+	input.SetNodeData(&data)
+	return nil
+}
+
+func (n *EncodeNode) Run(state *pipeline.State, input pipeline.RunInput, output *pipeline.RunOutput) error {
+	data := state.NodeData.(*encodeData)
+	eb := &oferrors.FirstBlock{}
+	for _, pin := range input.Pins {
+		td, ok := pipeline.Unwrap(pin.Payload).(*pipeline.TreeData)
+		if !ok {
+			output.Pins = append(output.Pins, pin)
+			continue
+		}
+		format := strings.ToLower(cmp.Or(data.Format, td.Format))
+		c, ok := codecs[format]
+		if !ok {
+			eb.AddError(fmt.Errorf("EncodeNode: %v: no format named \"%v\"", td.Name, format))
+			continue
+		}
+		dat, err := c.encode(td.Value, data)
+		if err != nil {
+			eb.AddError(fmt.Errorf("EncodeNode: %v: %w", td.Name, err))
+			continue
+		}
+		cd := &pipeline.ContentData{Name: td.Name, Data: string(dat), Format: format}
+		output.Pins = append(output.Pins, pipeline.Pin{Name: pin.Name, Payload: cd, Policy: pin.Policy})
+	}
+	return eb.Err
+}
+
+// codecFormat answers the format, or the format based
+// on the name's extension if format is empty.
+func codecFormat(name, format string) string {
+	if format != "" {
+		return strings.ToLower(format)
+	}
+	name = strings.ToLower(name)
+	for k := range codecs {
+		if strings.HasSuffix(name, "."+k) {
+			return k
+		}
+	}
+	return ""
+}
+
+// codec converts between bytes and a tree of values.
+type codec struct {
+	decode func(dat []byte) (any, error)
+	encode func(v any, data *encodeData) ([]byte, error)
+}
+
+var codecs = map[string]codec{
+	"json": {decode: decodeJson, encode: encodeJson},
+	"csv":  {decode: decodeCsv, encode: encodeCsv},
+	"xml":  {decode: decodeXml, encode: encodeXml},
+}
+
+func decodeJson(dat []byte) (any, error) {
+	var v any
+	err := json.Unmarshal(dat, &v)
+	return v, err
+}
+
+func encodeJson(v any, data *encodeData) ([]byte, error) {
+	if data.Indent != "" {
+		return json.MarshalIndent(v, "", data.Indent)
+	}
+	return json.Marshal(v)
+}
+
+func decodeCsv(dat []byte) (any, error) {
+	records, err := csv.NewReader(bytes.NewReader(dat)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]any, 0, len(records))
+	if len(records) < 1 {
+		return rows, nil
+	}
+	header := records[0]
+	for _, record := range records[1:] {
+		row := make(map[string]any, len(header))
+		for i, col := range header {
+			if i < len(record) {
+				row[col] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func encodeCsv(v any, data *encodeData) ([]byte, error) {
+	rows, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("csv requires a slice of rows, not %T", v)
+	}
+	var columns []string
+	if data.Columns != "" {
+		for _, col := range strings.Split(data.Columns, ",") {
+			columns = append(columns, strings.TrimSpace(col))
+		}
+	} else {
+		keys := make(map[string]struct{})
+		for _, row := range rows {
+			if m, ok := row.(map[string]any); ok {
+				for k := range m {
+					keys[k] = struct{}{}
+				}
+			}
+		}
+		columns = slices.Sorted(maps.Keys(keys))
+	}
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	w.Write(columns)
+	record := make([]string, len(columns))
+	for _, row := range rows {
+		m, ok := row.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("csv row must be a map, not %T", row)
+		}
+		for i, col := range columns {
+			record[i] = ""
+			if cv, ok := m[col]; ok && cv != nil {
+				record[i] = fmt.Sprintf("%v", cv)
+			}
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+const (
+	xmlAttrPrefix = "@"
+	xmlTextKey    = "#text"
+)
+
+func decodeXml(dat []byte) (any, error) {
+	type element struct {
+		name     string
+		children map[string]any
+		text     strings.Builder
+	}
+	// The root is a pseudo-element holding the document element.
+	stack := []*element{{children: make(map[string]any)}}
+	d := xml.NewDecoder(bytes.NewReader(dat))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			e := &element{name: t.Name.Local, children: make(map[string]any)}
+			for _, attr := range t.Attr {
+				e.children[xmlAttrPrefix+attr.Name.Local] = attr.Value
+			}
+			stack = append(stack, e)
+		case xml.CharData:
+			stack[len(stack)-1].text.Write(t)
+		case xml.EndElement:
+			e := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			var v any = e.children
+			text := strings.TrimSpace(e.text.String())
+			if len(e.children) < 1 {
+				v = text
+			} else if text != "" {
+				e.children[xmlTextKey] = text
+			}
+			parent := stack[len(stack)-1].children
+			switch existing := parent[e.name].(type) {
+			case nil:
+				parent[e.name] = v
+			case []any:
+				parent[e.name] = append(existing, v)
+			default:
+				parent[e.name] = []any{existing, v}
+			}
+		}
+	}
+	return stack[0].children, nil
+}
+
+func encodeXml(v any, data *encodeData) ([]byte, error) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("xml requires a map, not %T", v)
+	}
+	buf := &bytes.Buffer{}
+	e := xml.NewEncoder(buf)
+	if data.Indent != "" {
+		e.Indent("", data.Indent)
+	}
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		if err := encodeXmlElement(e, k, m[k]); err != nil {
+			return nil, err
+		}
+	}
+	err := e.Close()
+	return buf.Bytes(), err
+}
+
+func encodeXmlElement(e *xml.Encoder, name string, v any) error {
+	if s, ok := v.([]any); ok {
+		for _, item := range s {
+			if err := encodeXmlElement(e, name, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	m, ok := v.(map[string]any)
+	if !ok {
+		if v == nil {
+			v = ""
+		}
+		return e.EncodeElement(fmt.Sprintf("%v", v), start)
+	}
+	keys := slices.Sorted(maps.Keys(m))
+	for _, k := range keys {
+		if attr, ok := strings.CutPrefix(k, xmlAttrPrefix); ok {
+			start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: attr}, Value: fmt.Sprintf("%v", m[k])})
+		}
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if text, ok := m[xmlTextKey]; ok {
+		if err := e.EncodeToken(xml.CharData(fmt.Sprintf("%v", text))); err != nil {
+			return err
+		}
+	}
+	for _, k := range keys {
+		if k == xmlTextKey || strings.HasPrefix(k, xmlAttrPrefix) {
+			continue
+		}
+		if err := encodeXmlElement(e, k, m[k]); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
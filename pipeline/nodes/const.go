@@ -1,6 +1,8 @@
 package nodes
 
 import (
+	"encoding/json"
+	"fmt"
 	"regexp"
 
 	"github.com/hackborn/onefunc/pipeline"
@@ -30,4 +32,58 @@ var (
 			return nil
 		},
 	}
+
+	queryOperations = map[string]queryOperationFn{
+		"":       querySelect,
+		"select": querySelect,
+		"filter": func(state *pipeline.State, pin *pipeline.Pin, data *queryData) (bool, error) {
+			return queryMatch(pin, data), nil
+		},
+		"exclude": func(state *pipeline.State, pin *pipeline.Pin, data *queryData) (bool, error) {
+			return !queryMatch(pin, data), nil
+		},
+	}
+
+	transformOperations = map[string]transformOperationFn{
+		"set": func(td *pipeline.TreeData, data *transformData) error {
+			var v any
+			if err := json.Unmarshal([]byte(data.Value), &v); err != nil {
+				v = data.Value
+			}
+			return td.Set(data.Path, v)
+		},
+		"delete": func(td *pipeline.TreeData, data *transformData) error {
+			td.Delete(data.Path)
+			return nil
+		},
+	}
 )
+
+// querySelect replaces the tree with the value at the path.
+func querySelect(state *pipeline.State, pin *pipeline.Pin, data *queryData) (bool, error) {
+	td, ok := pipeline.Unwrap(pin.Payload).(*pipeline.TreeData)
+	if !ok {
+		return true, nil
+	}
+	if _, ok := td.Get(data.Path); !ok {
+		return false, nil
+	}
+	td = state.Mutable(pin).(*pipeline.TreeData)
+	v, _ := td.Get(data.Path)
+	td.Value = v
+	return true, nil
+}
+
+// queryMatch answers true if the tree has a value at
+// the path that matches the optional data value.
+func queryMatch(pin *pipeline.Pin, data *queryData) bool {
+	td, ok := pipeline.Unwrap(pin.Payload).(*pipeline.TreeData)
+	if !ok {
+		return false
+	}
+	v, ok := td.Get(data.Path)
+	if !ok {
+		return false
+	}
+	return data.Value == "" || fmt.Sprintf("%v", v) == data.Value
+}
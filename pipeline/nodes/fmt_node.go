@@ -25,7 +25,7 @@ func (n *FmtNode) Run(state *pipeline.State, input pipeline.RunInput, output *pi
 	fmt.Println("fmt run input pins:", len(input.Pins))
 	data := state.NodeData.(*fmtData)
 	for _, pin := range input.Pins {
-		switch p := pin.Payload.(type) {
+		switch p := pipeline.Unwrap(pin.Payload).(type) {
 		case *pipeline.ContentData:
 			n.runContentPin(data, p)
 		case *pipeline.StructData:
@@ -2,6 +2,8 @@ package nodes
 
 import (
 	"regexp"
+
+	"github.com/hackborn/onefunc/pipeline"
 )
 
 // regexpOperationFn abstracts performing the RegexpNode.Operation.
@@ -9,3 +11,10 @@ type regexpOperationFn func(re *regexp.Regexp, s string, data *regexpData) (stri
 
 // regexpTargetFn abstracts performing the RegexpNode.Target.
 type regexpTargetFn func(pin any, fn regexpOperationFn, re *regexp.Regexp, data *regexpData) error
+
+// queryOperationFn abstracts performing the QueryNode.Operation.
+// It answers false if the tree should be dropped.
+type queryOperationFn func(state *pipeline.State, pin *pipeline.Pin, data *queryData) (bool, error)
+
+// transformOperationFn abstracts performing the TransformNode.Operation.
+type transformOperationFn func(td *pipeline.TreeData, data *transformData) error
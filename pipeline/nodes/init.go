@@ -5,12 +5,30 @@ import (
 )
 
 func init() {
+	pipeline.RegisterNode("archive", func() pipeline.Node {
+		return &ArchiveNode{}
+	})
+	pipeline.RegisterNode("decode", func() pipeline.Node {
+		return &DecodeNode{}
+	})
+	pipeline.RegisterNode("encode", func() pipeline.Node {
+		return &EncodeNode{}
+	})
 	pipeline.RegisterNode("fmt", func() pipeline.Node {
 		return &FmtNode{}
 	})
 	pipeline.RegisterNode("load", func() pipeline.Node {
 		return &LoadFileNode{}
 	})
+	pipeline.RegisterNode("loadarchive", func() pipeline.Node {
+		return &LoadArchiveNode{}
+	})
+	pipeline.RegisterNode("merge", func() pipeline.Node {
+		return &MergeNode{}
+	})
+	pipeline.RegisterNode("query", func() pipeline.Node {
+		return &QueryNode{}
+	})
 	pipeline.RegisterNode("regexp", func() pipeline.Node {
 		return &RegexpNode{}
 	})
@@ -20,4 +38,7 @@ func init() {
 	pipeline.RegisterNode("struct", func() pipeline.Node {
 		return &StructNode{}
 	})
+	pipeline.RegisterNode("transform", func() pipeline.Node {
+		return &TransformNode{}
+	})
 }
@@ -5,10 +5,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
+	"testing/fstest"
 
+	ofio "github.com/hackborn/onefunc/io"
 	"github.com/hackborn/onefunc/jacl"
 	"github.com/hackborn/onefunc/pipeline"
+	"github.com/hackborn/onefunc/pipeline/pipelinetest"
 )
 
 func TestMain(m *testing.M) {
@@ -71,6 +75,119 @@ func TestLoad(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------
+// TEST-ARCHIVE
+func TestArchive(t *testing.T) {
+	dir := t.TempDir()
+	table := []struct {
+		pipeline string
+		cmp      []string
+		wantErr  error
+	}{
+		{`graph (loadarchive(Glob="` + testArchiveZip + `"))`, []string{`{count}=3`, `0/Payload/Name="readme.txt"`, `0/Payload/Data="read me"`, `2/Payload/Name="dir/b.go"`}, nil},
+		{`graph (loadarchive(Glob="` + testArchiveTarGz + `"))`, []string{`{count}=3`, `1/Payload/Name="dir/a.go"`, `1/Payload/Data="package a"`}, nil},
+		{`graph (loadarchive(Glob="` + testArchiveZip + `", Entry="dir/*"))`, []string{`{count}=2`, `0/Payload/Name="dir/a.go"`, `1/Payload/Name="dir/b.go"`}, nil},
+		{`graph (loadarchive(Fs="test", Glob="testdata/archive.*", Entry="*.txt"))`, []string{`{count}=2`, `0/Payload/Name="readme.txt"`, `1/Payload/Name="readme.txt"`}, nil},
+		{`graph (anna -> archive(Name="a.zip"))`, []string{`{count}=1`, `0/Payload/Name="a.zip"`, `0/Payload/Format="zip"`}, nil},
+		{`graph (anna -> archive(Name="a.zip") -> loadarchive)`, []string{`{count}=1`, `0/Payload/Name="Annabeth"`, `0/Payload/Data="born 2002 of fair skin and stout heart"`}, nil},
+		{`graph (anna -> archive(Name="a.tar") -> loadarchive)`, []string{`{count}=1`, `0/Payload/Name="Annabeth"`}, nil},
+		{`graph (anna -> archive(Name="a.tgz") -> loadarchive)`, []string{`{count}=1`, `0/Payload/Name="Annabeth"`}, nil},
+		{`graph (anna -> archive(Name="a", Format="tar.gz") -> loadarchive(Entry="x*"))`, []string{`{count}=0`}, nil},
+		{`graph (anna -> archive(Name="saved.zip", Path=$dir))`, []string{`{count}=1`}, nil},
+		{`graph (loadarchive(Glob=$saved))`, []string{`{count}=1`, `0/Payload/Name="Annabeth"`}, nil},
+		// Errors
+		{`graph (anna -> archive(Name="a.rar"))`, nil, fmt.Errorf("unknown format")},
+		{`graph (anna -> archive)`, nil, fmt.Errorf("no name")},
+		{`graph (loadarchive(Glob="` + testDataShortGlob + `"))`, nil, fmt.Errorf("unknown format")},
+		{`graph (hostile -> archive(Name="h.zip") -> loadarchive)`, nil, fmt.Errorf("archive entry \"../../x\" escapes the archive root")},
+		{`graph (hostile -> archive(Name="h.tar") -> loadarchive)`, nil, fmt.Errorf("archive entry \"../../x\" escapes the archive root")},
+		{`graph (rooted -> archive(Name="h.zip") -> loadarchive(Entry="*x"))`, nil, fmt.Errorf("archive entry \"/etc/x\" escapes the archive root")},
+		{`graph (anna -> archive(Name="../saved.zip", Path=$dir))`, nil, fmt.Errorf("name \"../saved.zip\" escapes the path")},
+	}
+	env := map[string]any{`$dir`: dir, `$saved`: filepath.Join(dir, "saved.zip")}
+	for i, v := range table {
+		output, haveErr := pipeline.RunExpr(v.pipeline, nil, env)
+		if err := jacl.RunErr(haveErr, v.wantErr); err != nil {
+			t.Fatalf("TestArchive %v %v", i, err.Error())
+		} else if haveErr == nil {
+			if err = jacl.Run(output.Pins, v.cmp...); err != nil {
+				t.Fatalf("TestArchive %v comparison error: %v", i, err)
+			}
+		}
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-CODEC
+func TestCodec(t *testing.T) {
+	table := []struct {
+		pipeline string
+		cmp      []string
+		wantErr  error
+	}{
+		{`graph (load(Fs=$fs, Glob="a.json") -> decode -> encode)`, []string{`0/Payload/Name="a.json"`, `0/Payload/Data="{''app'':{''name'':''a'',''port'':80},''tags'':[''x'',''y'']}"`}, nil},
+		{`graph (load(Fs=$fs, Glob="a.json") -> decode -> encode(Format=xml))`, []string{`0/Payload/Data="<app><name>a</name><port>80</port></app><tags>x</tags><tags>y</tags>"`}, nil},
+		{`graph (load(Fs=$fs, Glob="a.csv") -> decode -> encode(Format=json))`, []string{`0/Payload/Data="[{''id'':''1'',''name'':''ann''},{''id'':''2'',''name'':''bo''}]"`}, nil},
+		{`graph (load(Fs=$fs, Glob="a.csv") -> decode/a -> encode/a(Columns="name") -> decode/b -> encode/b(Format=json))`, []string{`0/Payload/Data="[{''name'':''ann''},{''name'':''bo''}]"`}, nil},
+		{`graph (load(Fs=$fs, Glob="a.xml") -> decode -> encode(Format=json))`, []string{`0/Payload/Data="{''cfg'':{''@v'':''2'',''item'':[{''#text'':''one'',''@id'':''1''},''two'']}}"`}, nil},
+		{`graph (load(Fs=$fs, Glob="a.xml") -> decode -> encode)`, []string{`0/Payload/Data="<cfg v=''2''><item id=''1''>one</item><item>two</item></cfg>"`}, nil},
+		{`graph (load(Fs=$fs, Glob="a.txt") -> decode(Format=json) -> encode)`, []string{`0/Payload/Format="json"`, `0/Payload/Data="[1]"`}, nil},
+		{`graph (load(Fs=$fs, Glob="a.txt") -> decode)`, []string{`0/Payload/{type}="*ContentData"`}, nil},
+		// Errors
+		{`graph (load(Fs=$fs, Glob="bad.txt") -> decode(Format=json))`, nil, fmt.Errorf("bad json")},
+		{`graph (load(Fs=$fs, Glob="a.json") -> decode -> encode(Format=yaml))`, nil, fmt.Errorf("no format")},
+		{`graph (load(Fs=$fs, Glob="a.json") -> decode -> encode(Format=csv))`, nil, fmt.Errorf("not rows")},
+	}
+	for i, v := range table {
+		output, haveErr := pipelinetest.RunFS(t, testTreeFs, v.pipeline, nil)
+		if err := jacl.RunErr(haveErr, v.wantErr); err != nil {
+			t.Fatalf("TestCodec %v %v", i, err.Error())
+		} else if haveErr == nil {
+			if err = jacl.Run(output.Pins, v.cmp...); err != nil {
+				t.Fatalf("TestCodec %v comparison error: %v", i, err)
+			}
+		}
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-TREE
+func TestTree(t *testing.T) {
+	table := []struct {
+		pipeline string
+		cmp      []string
+		wantErr  error
+	}{
+		{`graph (load(Fs=$fs, Glob="a.json") -> decode -> query(Path="app") -> encode)`, []string{`0/Payload/Data="{''name'':''a'',''port'':80}"`}, nil},
+		{`graph (load(Fs=$fs, Glob="a.json") -> decode -> query(Path="tags/1") -> encode)`, []string{`0/Payload/Data="''y''"`}, nil},
+		{`graph (load(Fs=$fs, Glob="*.json") -> decode -> query(Path="app/port") -> encode)`, []string{`{count}=2`, `0/Payload/Data="80"`, `1/Payload/Data="81"`}, nil},
+		{`graph (load(Fs=$fs, Glob="*.json") -> decode -> query(Path="app/missing"))`, []string{`{count}=0`}, nil},
+		{`graph (load(Fs=$fs, Glob="*.json") -> decode -> query(Path="app/port", Operation=filter, Value=81))`, []string{`{count}=1`, `0/Payload/Name="b.json"`}, nil},
+		{`graph (load(Fs=$fs, Glob="*.json") -> decode -> query(Path="tags", Operation=filter))`, []string{`{count}=1`, `0/Payload/Name="a.json"`}, nil},
+		{`graph (load(Fs=$fs, Glob="*.json") -> decode -> query(Path="tags", Operation=exclude))`, []string{`{count}=1`, `0/Payload/Name="b.json"`}, nil},
+		{`graph (load(Fs=$fs, Glob="a.json") -> decode -> transform(Operation=set, Path="app/port", Value=8080) -> encode)`, []string{`0/Payload/Data="{''app'':{''name'':''a'',''port'':8080},''tags'':[''x'',''y'']}"`}, nil},
+		{`graph (load(Fs=$fs, Glob="a.json") -> decode -> transform(Operation=set, Path="new/key", Value=v) -> query(Path="new") -> encode)`, []string{`0/Payload/Data="{''key'':''v''}"`}, nil},
+		{`graph (load(Fs=$fs, Glob="a.json") -> decode -> transform(Operation=set, Path="tags/2", Value=z) -> query(Path="tags") -> encode)`, []string{`0/Payload/Data="[''x'',''y'',''z'']"`}, nil},
+		{`graph (load(Fs=$fs, Glob="a.json") -> decode -> transform(Operation=delete, Path="tags/0") -> encode)`, []string{`0/Payload/Data="{''app'':{''name'':''a'',''port'':80},''tags'':[''y'']}"`}, nil},
+		{`graph (load(Fs=$fs, Glob="a.json") -> decode -> transform(Operation=delete, Path="app") -> encode)`, []string{`0/Payload/Data="{''tags'':[''x'',''y'']}"`}, nil},
+		{`graph (load(Fs=$fs, Glob="?.json") -> decode -> merge(Name="m.json") -> encode)`, []string{`{count}=1`, `0/Payload/Name="m.json"`, `0/Payload/Data="{''app'':{''debug'':true,''name'':''a'',''port'':81},''tags'':[''x'',''y'']}"`}, nil},
+		// Errors
+		{`graph (load(Fs=$fs, Glob="a.json") -> decode -> query(Path="a", Operation=nope))`, nil, fmt.Errorf("no operation")},
+		{`graph (load(Fs=$fs, Glob="a.json") -> decode -> transform(Path="a"))`, nil, fmt.Errorf("no operation")},
+		{`graph (load(Fs=$fs, Glob="a.json") -> decode -> transform(Operation=set, Path="tags/9", Value=z))`, nil, fmt.Errorf("bad index")},
+	}
+	for i, v := range table {
+		output, haveErr := pipelinetest.RunFS(t, testTreeFs, v.pipeline, nil)
+		if err := jacl.RunErr(haveErr, v.wantErr); err != nil {
+			t.Fatalf("TestTree %v %v", i, err.Error())
+		} else if haveErr == nil {
+			if err = jacl.Run(output.Pins, v.cmp...); err != nil {
+				t.Fatalf("TestTree %v comparison error: %v", i, err)
+			}
+		}
+	}
+}
+
 // ---------------------------------------------------------
 // TEST-PIPELINE
 func TestPipeline(t *testing.T) {
@@ -113,6 +230,70 @@ func TestPipeline(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------
+// TEST-RECORD
+func TestRecord(t *testing.T) {
+	table := []struct {
+		pipeline string
+		replay   string
+		cmp      []string
+		corrupt  string
+		wantErr  error
+	}{
+		{`graph (anna -> regexp(Target="Content.Name",Expr="be"))`, "regexp", []string{`0/Payload/Name="Annath"`}, "", nil},
+		{`graph (anna -> regexp(Target="Content.Name",Expr="be"))`, "anna", []string{`0/Payload/Name="Annabeth"`}, "", nil},
+		{`graph (anna -> regexp/a(Target="Content.Name",Expr="be") -> regexp/b(Target="Content.Name",Expr="th"))`, "regexp/b", []string{`0/Payload/Name="Anna"`}, "regexp/a", nil},
+		{`graph (load(Fs="test", Glob="` + testEmbedShortGlob + `"))`, "load", []string{`0/Payload/Data="a"`}, "load", nil},
+		// Errors
+		{`graph (anna)`, "missing", nil, "", fmt.Errorf("no node")},
+	}
+	for i, v := range table {
+		p, err := pipeline.Compile(v.pipeline)
+		if err != nil {
+			t.Fatalf("TestRecord %v compile err %v", i, err)
+		}
+		_, rec, err := pipeline.Record(p, nil, nil)
+		if err != nil {
+			t.Fatalf("TestRecord %v record err %v", i, err)
+		}
+		// Round trip the recording to make sure it survives serialization.
+		fn := filepath.Join(t.TempDir(), "rec.json")
+		if err = ofio.WriteJson(fn, rec); err != nil {
+			t.Fatalf("TestRecord %v write err %v", i, err)
+		}
+		loaded, err := ofio.ReadJson[pipeline.Recording](fn)
+		if err != nil {
+			t.Fatalf("TestRecord %v read err %v", i, err)
+		}
+		output, haveErr := pipeline.Replay(&loaded, v.replay)
+		if err = jacl.RunErr(haveErr, v.wantErr); err != nil {
+			t.Fatalf("TestRecord %v %v", i, err.Error())
+		} else if haveErr != nil {
+			continue
+		}
+		if err = jacl.Run(output.Pins, v.cmp...); err != nil {
+			t.Fatalf("TestRecord %v comparison error: %v", i, err)
+		}
+		// Nothing should diverge until the recording is altered.
+		diverged, err := pipeline.Diverged(&loaded)
+		if err != nil {
+			t.Fatalf("TestRecord %v diverged err %v", i, err)
+		} else if len(diverged) > 0 {
+			t.Fatalf("TestRecord %v has diverged nodes %v but wanted none", i, diverged)
+		}
+		if v.corrupt != "" {
+			rn, _ := loaded.Node(v.corrupt)
+			rn.Output = rn.Output[:0]
+			diverged, err = pipeline.Diverged(&loaded)
+			if err != nil {
+				t.Fatalf("TestRecord %v diverged err %v", i, err)
+			} else if slices.Compare(diverged, []string{v.corrupt}) != 0 {
+				t.Fatalf("TestRecord %v has diverged nodes %v but wanted %v", i, diverged, v.corrupt)
+			}
+		}
+	}
+}
+
 // ---------------------------------------------------------
 // SUPPORT
 
@@ -139,6 +320,26 @@ func setupTests() {
 		n.data = append(n.data, &pipeline.ContentData{Name: "Annabeth", Data: "born 2002 of fair skin and stout heart"})
 		return n
 	})
+	// hostile and rooted supply names that escape a folder, to make zip slip archives.
+	pipeline.RegisterNode("hostile", func() pipeline.Node {
+		n := &contentSrcNode{}
+		n.data = append(n.data, &pipeline.ContentData{Name: "../../x", Data: "x"})
+		return n
+	})
+	pipeline.RegisterNode("rooted", func() pipeline.Node {
+		n := &contentSrcNode{}
+		n.data = append(n.data, &pipeline.ContentData{Name: "/etc/x", Data: "x"})
+		return n
+	})
+}
+
+var testTreeFs = fstest.MapFS{
+	"a.json":  {Data: []byte(`{"app": {"name": "a", "port": 80}, "tags": ["x", "y"]}`)},
+	"b.json":  {Data: []byte(`{"app": {"port": 81, "debug": true}}`)},
+	"bad.txt": {Data: []byte(`{"app": `)},
+	"a.csv":   {Data: []byte("id,name\n1,ann\n2,bo\n")},
+	"a.xml":   {Data: []byte(`<cfg v="2"><item id="1">one</item><item>two</item></cfg>`)},
+	"a.txt":   {Data: []byte(`[1]`)},
 }
 
 //go:embed testdata/*
@@ -150,4 +351,6 @@ var (
 	testDataShortGlob  = filepath.Join(".", "testdata", "short_*")
 	testGroupsGlob     = filepath.Join(".", "testdata", "groupa/*") + ";" + filepath.Join(".", "testdata", "groupb/*")
 	testEmbedShortGlob = "testdata/short_*"
+	testArchiveZip     = filepath.Join(".", "testdata", "archive.zip")
+	testArchiveTarGz   = filepath.Join(".", "testdata", "archive.tar.gz")
 )
@@ -51,7 +51,7 @@ func (n *RegexpNode) Run(state *pipeline.State, input pipeline.RunInput, output
 	output.Pins = make([]pipeline.Pin, 0, len(input.Pins))
 	eb := &oferrors.FirstBlock{}
 	for _, pin := range input.Pins {
-		eb.AddError(setFn(pin.Payload, runFn, re, data))
+		eb.AddError(setFn(state.Mutable(&pin), runFn, re, data))
 		output.Pins = append(output.Pins, pin)
 	}
 	return eb.Err
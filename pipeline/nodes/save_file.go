@@ -33,7 +33,7 @@ func (n *SaveFileNode) Run(state *pipeline.State, input pipeline.RunInput, outpu
 		return err
 	}
 	for _, pin := range input.Pins {
-		switch p := pin.Payload.(type) {
+		switch p := pipeline.Unwrap(pin.Payload).(type) {
 		case *pipeline.ContentData:
 			err = n.runContentPin(state, p, path)
 			if err != nil {
@@ -32,7 +32,7 @@ func (n *StructNode) Start(input pipeline.StartInput) error {
 func (n *StructNode) Run(state *pipeline.State, input pipeline.RunInput, output *pipeline.RunOutput) error {
 	data := state.NodeData.(*structData)
 	for _, pin := range input.Pins {
-		switch t := pin.Payload.(type) {
+		switch t := pipeline.Unwrap(pin.Payload).(type) {
 		case *pipeline.ContentData:
 			err := n.runContent(data, t, output)
 			if err != nil {
@@ -0,0 +1,169 @@
+package nodes
+
+import (
+	"fmt"
+	"strings"
+
+	oferrors "github.com/hackborn/onefunc/errors"
+	"github.com/hackborn/onefunc/pipeline"
+)
+
+// QueryNode selects or filters TreeData by path.
+// All other pins are passed through.
+type QueryNode struct {
+	queryData
+}
+
+type queryData struct {
+	// Path to the queried value, in cfg.Settings syntax
+	// ("path/to/value", "rows/0/name").
+	Path string
+
+	// The query operation. Supported:
+	// "" -- default to "select"
+	// "select" -- replace the tree with the value at Path.
+	// Trees without a value at Path are dropped.
+	// "filter" -- keep only trees with a value at Path.
+	// "exclude" -- drop all trees with a value at Path.
+	Operation string
+
+	// Optional value for the "filter" and "exclude" operations.
+	// If set, the value at Path must also match it, as formatted
+	// with fmt's %v.
+	Value string
+}
+
+func (n *QueryNode) Start(input pipeline.StartInput) error {
+	data := n.queryData
+	input.SetNodeData(&data)
+	return nil
+}
+
+func (n *QueryNode) Run(state *pipeline.State, input pipeline.RunInput, output *pipeline.RunOutput) error {
+	data := state.NodeData.(*queryData)
+	fn, ok := queryOperations[strings.ToLower(data.Operation)]
+	if !ok {
+		return fmt.Errorf("QueryNode: No operation named \"%v\"", data.Operation)
+	}
+	eb := &oferrors.FirstBlock{}
+	for _, pin := range input.Pins {
+		if _, ok := pipeline.Unwrap(pin.Payload).(*pipeline.TreeData); !ok {
+			output.Pins = append(output.Pins, pin)
+			continue
+		}
+		keep, err := fn(state, &pin, data)
+		eb.AddError(err)
+		if keep {
+			output.Pins = append(output.Pins, pin)
+		}
+	}
+	return eb.Err
+}
+
+// TransformNode modifies TreeData by path.
+// All other pins are passed through.
+type TransformNode struct {
+	transformData
+}
+
+type transformData struct {
+	// Path to the modified value, in cfg.Settings syntax.
+	Path string
+
+	// The transform operation. Supported:
+	// "set" -- set Value at Path.
+	// "delete" -- delete the value at Path.
+	Operation string
+
+	// The value for the "set" operation. It is decoded as
+	// JSON when possible ("10", "true", "[1, 2]"), otherwise
+	// it is used as a string.
+	Value string
+}
+
+func (n *TransformNode) Start(input pipeline.StartInput) error {
+	data := n.transformData
+	input.SetNodeData(&data)
+	return nil
+}
+
+func (n *TransformNode) Run(state *pipeline.State, input pipeline.RunInput, output *pipeline.RunOutput) error {
+	data := state.NodeData.(*transformData)
+	fn, ok := transformOperations[strings.ToLower(data.Operation)]
+	if !ok {
+		return fmt.Errorf("TransformNode: No operation named \"%v\"", data.Operation)
+	}
+	eb := &oferrors.FirstBlock{}
+	for _, pin := range input.Pins {
+		if _, ok := pipeline.Unwrap(pin.Payload).(*pipeline.TreeData); ok {
+			eb.AddError(fn(state.Mutable(&pin).(*pipeline.TreeData), data))
+		}
+		output.Pins = append(output.Pins, pin)
+	}
+	return eb.Err
+}
+
+// MergeNode deep merges all incoming TreeData into a single
+// tree. Maps are merged key by key; for any other value, later
+// trees replace earlier ones. All other pins are passed through.
+type MergeNode struct {
+	mergeData
+}
+
+type mergeData struct {
+	// Name of the merged tree.
+	Name string
+
+	// Optional format of the merged tree. By default the
+	// format of the first tree is used.
+	Format string
+
+	merged *pipeline.TreeData
+}
+
+func (n *MergeNode) Start(input pipeline.StartInput) error {
+	data := n.mergeData
+	input.SetNodeData(&data)
+	return nil
+}
+
+func (n *MergeNode) Run(state *pipeline.State, input pipeline.RunInput, output *pipeline.RunOutput) error {
+	data := state.NodeData.(*mergeData)
+	for _, pin := range input.Pins {
+		td, ok := pipeline.Unwrap(pin.Payload).(*pipeline.TreeData)
+		if !ok {
+			output.Pins = append(output.Pins, pin)
+			continue
+		}
+		if data.merged == nil {
+			data.merged = &pipeline.TreeData{Name: data.Name, Format: data.Format}
+			if data.merged.Format == "" {
+				data.merged.Format = td.Format
+			}
+		}
+		// The merged tree is assembled from clones so
+		// the incoming trees are never modified.
+		data.merged.Value = mergeTree(data.merged.Value, td.Clone().(*pipeline.TreeData).Value)
+	}
+	return nil
+}
+
+func (n *MergeNode) Flush(state *pipeline.State, output *pipeline.RunOutput) error {
+	data := state.NodeData.(*mergeData)
+	if data.merged != nil {
+		output.Pins = append(output.Pins, pipeline.Pin{Payload: data.merged})
+	}
+	return nil
+}
+
+func mergeTree(left, right any) any {
+	lm, lok := left.(map[string]any)
+	rm, rok := right.(map[string]any)
+	if !lok || !rok {
+		return right
+	}
+	for k, v := range rm {
+		lm[k] = mergeTree(lm[k], v)
+	}
+	return lm
+}
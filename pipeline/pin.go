@@ -11,6 +11,11 @@ type Cloner interface {
 	Clone() Cloner
 }
 
+// ClonePolicy determines when a payload is cloned as it is
+// delivered to destination nodes. Payloads that are expensive to
+// clone can be wrapped with NewCow, which makes every clone a
+// cheap shared reference until a node asks State.Mutable() for
+// a copy it can modify.
 type ClonePolicy int
 
 const (
@@ -28,11 +28,13 @@ type compiledPin struct {
 // compiledNode is generated as part of the compilation.
 // It is immutable and thread-safe.
 type compiledNode struct {
+	name          string
 	node          Runner
 	flusher       Flusher
 	starter       Starter
 	maxInputCount int
 	output        []*compiledPin
+	vars          map[string]any
 	envVars       map[string]string
 }
 
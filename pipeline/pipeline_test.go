@@ -154,6 +154,57 @@ func runAsString(expr, input string, env map[string]any) ([]string, error) {
 	return ans, nil
 }
 
+// ---------------------------------------------------------
+// TEST-COW-CLONES
+func TestCowClones(t *testing.T) {
+	wide := func(src string, dsts ...string) string {
+		expr := "graph ("
+		for _, dst := range dsts {
+			expr += " " + src + " -> " + dst
+		}
+		return expr + ")"
+	}
+	readers := []string{"rd/0", "rd/1", "rd/2", "rd/3", "rd/4", "rd/5", "rd/6", "rd/7"}
+	abc := slices.Sorted(slices.Values(slices.Repeat([]string{"a", "b", "c"}, len(readers))))
+	table := []struct {
+		pipeline   string
+		wantClones int
+		want       []string
+	}{
+		// Plain payloads are cloned for every destination after the first.
+		{wide("cs(S=a)", readers...), 7, []string{"a", "a", "a", "a", "a", "a", "a", "a"}},
+		{wide(`cs(S="a;b;c")`, readers...), 21, abc},
+		// Cow payloads are shared by readers.
+		{wide("cs(S=a, Cow=true)", readers...), 0, []string{"a", "a", "a", "a", "a", "a", "a", "a"}},
+		{wide(`cs(S="a;b;c", Cow=true)`, readers...), 0, abc},
+		// Cow payloads are only copied for writers that share them.
+		{wide("cs(S=a, Cow=true)", "wr/0(S=!)"), 0, []string{"a!"}},
+		{wide("cs(S=a, Cow=true)", "wr/0(S=!)", "rd/0", "rd/1"), 1, []string{"a", "a", "a!"}},
+		{wide("cs(S=a, Cow=true)", "wr/0(S=!)", "wr/1(S=?)", "rd/0"), 2, []string{"a", "a!", "a?"}},
+		{wide("cs(S=a, Cow=true)", "wr/0(S=!)", "wr/1(S=?)"), 1, []string{"a!", "a?"}},
+		{wide("cs(S=a)", "wr/0(S=!)", "wr/1(S=?)"), 1, []string{"a!", "a?"}},
+	}
+	for i, v := range table {
+		cloneCount = 0
+		ro, err := RunExpr(v.pipeline, nil, nil)
+		if err != nil {
+			t.Fatalf("TestCowClones %v has err %v", i, err)
+		}
+		var have []string
+		for _, pin := range ro.Pins {
+			if cd, ok := Unwrap(pin.Payload).(*countData); ok {
+				have = append(have, cd.s)
+			}
+		}
+		slices.Sort(have)
+		if cloneCount != v.wantClones {
+			t.Fatalf("TestCowClones %v has %v clones but wanted %v", i, cloneCount, v.wantClones)
+		} else if slices.Compare(have, v.want) != 0 {
+			t.Fatalf("TestCowClones %v has \"%v\" but wanted \"%v\"", i, have, v.want)
+		}
+	}
+}
+
 // ---------------------------------------------------------
 // BENCHMARK-PARSER
 func BenchmarkParser(b *testing.B) {
@@ -253,6 +304,19 @@ func (d *valueData) Clone() Cloner {
 	return &dst
 }
 
+// countData is a payload that counts its clones.
+type countData struct {
+	s string
+}
+
+func (d *countData) Clone() Cloner {
+	cloneCount++
+	dst := *d
+	return &dst
+}
+
+var cloneCount int
+
 // ---------------------------------------------------------
 // NODES
 
@@ -347,6 +411,64 @@ func (n *nodeNc) Flush(state *State, output *RunOutput) error {
 	return nil
 }
 
+// nodeCs is a source of countData. It generates a payload
+// for each ";" separated value in S, optionally wrapped in a Cow.
+type nodeCs struct {
+	S   string
+	Cow bool
+}
+
+func (n *nodeCs) Run(state *State, input RunInput, output *RunOutput) error {
+	for _, s := range strings.Split(n.S, ";") {
+		var payload Cloner = &countData{s: s}
+		if n.Cow {
+			payload = NewCow(payload)
+		}
+		output.Pins = append(output.Pins, Pin{Payload: payload})
+	}
+	return nil
+}
+
+// nodeRd reads countData, passing it through untouched.
+type nodeRd struct {
+}
+
+func (n *nodeRd) Run(state *State, input RunInput, output *RunOutput) error {
+	for _, p := range input.Pins {
+		if _, ok := Unwrap(p.Payload).(*countData); !ok {
+			return fmt.Errorf("nodeRd unexpected payload %T", p.Payload)
+		}
+		output.Pins = append(output.Pins, p)
+	}
+	return nil
+}
+
+// nodeWr appends its string value to countData in place.
+type nodeWr struct {
+	nodeWrData
+}
+
+type nodeWrData struct {
+	S string
+}
+
+func (n *nodeWr) Start(input StartInput) error {
+	data := n.nodeWrData
+	input.SetNodeData(&data)
+	return nil
+}
+
+func (n *nodeWr) Run(state *State, input RunInput, output *RunOutput) error {
+	data := state.NodeData.(*nodeWrData)
+	for _, p := range input.Pins {
+		if cd, ok := state.Mutable(&p).(*countData); ok {
+			cd.s += data.S
+		}
+		output.Pins = append(output.Pins, p)
+	}
+	return nil
+}
+
 // ---------------------------------------------------------
 // LIFECYCLE
 
@@ -360,6 +482,15 @@ func setupTests() {
 	RegisterNode("nc", func() Node {
 		return &nodeNc{}
 	})
+	RegisterNode("cs", func() Node {
+		return &nodeCs{}
+	})
+	RegisterNode("rd", func() Node {
+		return &nodeRd{}
+	})
+	RegisterNode("wr", func() Node {
+		return &nodeWr{}
+	})
 
 	// Aliases
 	RegisterNode("na1", func() Node {
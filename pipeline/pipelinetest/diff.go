@@ -0,0 +1,61 @@
+package pipelinetest
+
+import (
+	"strings"
+)
+
+// Diff answers a readable line diff between want and have.
+// Lines only in want are prefixed with "- ", lines only in have
+// with "+ ", and shared lines with "  ". An empty string means
+// there is no difference.
+func Diff(want, have string) string {
+	if want == have {
+		return ""
+	}
+	a := strings.Split(want, "\n")
+	b := strings.Split(have, "\n")
+	// Longest common subsequence table, lcs[i][j] is the
+	// length of the LCS of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	sb := strings.Builder{}
+	sb.WriteString("--- want\n+++ have\n")
+	write := func(prefix, line string) {
+		sb.WriteString(prefix)
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			write("  ", a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			write("- ", a[i])
+			i++
+		default:
+			write("+ ", b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		write("- ", a[i])
+	}
+	for ; j < len(b); j++ {
+		write("+ ", b[j])
+	}
+	return sb.String()
+}
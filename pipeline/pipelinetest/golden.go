@@ -0,0 +1,119 @@
+package pipelinetest
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hackborn/onefunc/pipeline"
+)
+
+// Run the tests with -pipelinetest.update to rewrite the golden
+// files. The flag is namespaced so it can't collide with a flag
+// defined by the test binary.
+var update = flag.Bool("pipelinetest.update", false, "update pipelinetest golden files")
+
+// Golden compares the ContentData in pins against the golden
+// files in dir. Each ContentData must have a matching file named
+// after ContentData.Name, and every file in dir must have a
+// matching ContentData. Failures are reported with a Diff().
+//
+// When the test is run with -pipelinetest.update, dir is made to
+// match the pins instead: files are written for each ContentData
+// and any other files are removed. dir should therefore hold
+// nothing but golden files.
+func Golden(t testing.TB, pins []pipeline.Pin, dir string) {
+	t.Helper()
+	content := Content(pins)
+	if *update {
+		if err := writeGolden(content, dir); err != nil {
+			t.Fatalf("golden %v update err: %v", dir, err)
+		}
+		return
+	}
+	if err := CompareGolden(content, dir); err != nil {
+		t.Fatalf("golden %v", err)
+	}
+}
+
+// CompareGolden answers an error describing every difference
+// between the content and the golden files in dir.
+func CompareGolden(content []*pipeline.ContentData, dir string) error {
+	files, err := goldenFiles(dir)
+	if err != nil {
+		return err
+	}
+	var msgs []string
+	seen := make(map[string]struct{})
+	for _, cd := range content {
+		seen[cd.Name] = struct{}{}
+		if !slices.Contains(files, cd.Name) {
+			msgs = append(msgs, fmt.Sprintf("%v: no golden file", cd.Name))
+			continue
+		}
+		dat, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(cd.Name)))
+		if err != nil {
+			return err
+		}
+		if want := string(dat); want != cd.Data {
+			msgs = append(msgs, fmt.Sprintf("%v: content differs\n%v", cd.Name, Diff(want, cd.Data)))
+		}
+	}
+	for _, name := range files {
+		if _, ok := seen[name]; !ok {
+			msgs = append(msgs, fmt.Sprintf("%v: golden file has no content", name))
+		}
+	}
+	if len(msgs) > 0 {
+		return fmt.Errorf("%v mismatch:\n%v", dir, strings.Join(msgs, "\n"))
+	}
+	return nil
+}
+
+func writeGolden(content []*pipeline.ContentData, dir string) error {
+	files, err := goldenFiles(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	keep := make(map[string]struct{})
+	for _, cd := range content {
+		fn := filepath.Join(dir, filepath.FromSlash(cd.Name))
+		if err = os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			return err
+		}
+		if err = os.WriteFile(fn, []byte(cd.Data), 0644); err != nil {
+			return err
+		}
+		keep[cd.Name] = struct{}{}
+	}
+	for _, name := range files {
+		if _, ok := keep[name]; !ok {
+			if err = os.Remove(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// goldenFiles answers the slash-separated names of every
+// file under dir.
+func goldenFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	return files, err
+}
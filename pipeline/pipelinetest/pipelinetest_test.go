@@ -0,0 +1,131 @@
+package pipelinetest
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hackborn/onefunc/jacl"
+	"github.com/hackborn/onefunc/pipeline"
+	_ "github.com/hackborn/onefunc/pipeline/nodes"
+)
+
+// ---------------------------------------------------------
+// TEST-RUN-FS
+func TestRunFS(t *testing.T) {
+	table := []struct {
+		pipeline string
+		cmp      []string
+		wantErr  error
+	}{
+		{`graph (load(Fs=$fs, Glob="*.txt"))`, []string{`0/Payload/Name="a.txt"`, `0/Payload/Data="alpha"`, `1/Payload/Data="beta"`}, nil},
+		{`graph (load(Fs=$fs, Glob="sub/*"))`, []string{`0/Payload/Name="c.txt"`}, nil},
+		// Errors
+		{`graph (load(Fs=$nofs, Glob="*.txt"))`, nil, fmt.Errorf("missing env")},
+	}
+	for i, v := range table {
+		output, haveErr := RunFS(t, testFs, v.pipeline, nil)
+		if err := jacl.RunErr(haveErr, v.wantErr); err != nil {
+			t.Fatalf("TestRunFS %v %v", i, err.Error())
+		} else if haveErr == nil {
+			if err = jacl.Run(output.Pins, v.cmp...); err != nil {
+				t.Fatalf("TestRunFS %v comparison error: %v", i, err)
+			}
+		}
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-GOLDEN
+func TestGolden(t *testing.T) {
+	output := MustRunFS(t, goldenFs, `graph (load(Fs=$fs, Glob="*.txt"))`, nil)
+	Golden(t, output.Pins, filepath.Join("testdata", "load"))
+}
+
+// ---------------------------------------------------------
+// TEST-GOLDEN-FLAG
+func TestGoldenFlag(t *testing.T) {
+	// Importing the package must leave common flag names free.
+	if flag.Lookup("update") != nil {
+		t.Fatalf("TestGoldenFlag registers -update")
+	} else if flag.Lookup("pipelinetest.update") == nil {
+		t.Fatalf("TestGoldenFlag missing -pipelinetest.update")
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-COMPARE-GOLDEN
+func TestCompareGolden(t *testing.T) {
+	dir := filepath.Join("testdata", "load")
+	table := []struct {
+		content []*pipeline.ContentData
+		wantErr error
+	}{
+		{[]*pipeline.ContentData{{Name: "a.txt", Data: "alpha"}, {Name: "b.txt", Data: "beta\ngamma"}}, nil},
+		// Errors
+		{[]*pipeline.ContentData{{Name: "a.txt", Data: "alpha"}}, fmt.Errorf("missing content")},
+		{[]*pipeline.ContentData{{Name: "a.txt", Data: "alpha"}, {Name: "b.txt", Data: "beta\ngamma"}, {Name: "c.txt"}}, fmt.Errorf("missing golden")},
+		{[]*pipeline.ContentData{{Name: "a.txt", Data: "alpha"}, {Name: "b.txt", Data: "beta"}}, fmt.Errorf("different content")},
+	}
+	for i, v := range table {
+		haveErr := CompareGolden(v.content, dir)
+		if err := jacl.RunErr(haveErr, v.wantErr); err != nil {
+			t.Fatalf("TestCompareGolden %v %v", i, err.Error())
+		}
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-WRITE-GOLDEN
+func TestWriteGolden(t *testing.T) {
+	dir := t.TempDir()
+	first := []*pipeline.ContentData{{Name: "a.txt", Data: "a"}, {Name: "sub/b.txt", Data: "b"}}
+	second := []*pipeline.ContentData{{Name: "sub/b.txt", Data: "b2"}}
+	if err := writeGolden(first, dir); err != nil {
+		t.Fatalf("TestWriteGolden write err %v", err)
+	} else if err = CompareGolden(first, dir); err != nil {
+		t.Fatalf("TestWriteGolden compare err %v", err)
+	}
+	// Stale files are removed.
+	if err := writeGolden(second, dir); err != nil {
+		t.Fatalf("TestWriteGolden write err %v", err)
+	} else if err = CompareGolden(second, dir); err != nil {
+		t.Fatalf("TestWriteGolden compare err %v", err)
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-DIFF
+func TestDiff(t *testing.T) {
+	table := []struct {
+		want string
+		have string
+		diff string
+	}{
+		{"a", "a", ""},
+		{"a", "b", "--- want\n+++ have\n- a\n+ b\n"},
+		{"a\nb\nc", "a\nc", "--- want\n+++ have\n  a\n- b\n  c\n"},
+		{"a\nc", "a\nb\nc", "--- want\n+++ have\n  a\n+ b\n  c\n"},
+		{"a\nb", "a\nb\nc", "--- want\n+++ have\n  a\n  b\n+ c\n"},
+	}
+	for i, v := range table {
+		have := Diff(v.want, v.have)
+		if have != v.diff {
+			t.Fatalf("TestDiff %v has \"%v\" but wanted \"%v\"", i, have, v.diff)
+		}
+	}
+}
+
+var (
+	testFs = fstest.MapFS{
+		"a.txt":     {Data: []byte("alpha")},
+		"b.txt":     {Data: []byte("beta")},
+		"sub/c.txt": {Data: []byte("gamma")},
+	}
+	goldenFs = fstest.MapFS{
+		"a.txt": {Data: []byte("alpha")},
+		"b.txt": {Data: []byte("beta\ngamma")},
+	}
+)
@@ -0,0 +1,60 @@
+package pipelinetest
+
+import (
+	"fmt"
+	"io/fs"
+	"maps"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hackborn/onefunc/pipeline"
+)
+
+// FsEnv is the env var that RunFS assigns the temporary
+// filesystem name to, i.e. `graph (load(Fs=$fs, Glob="*.txt"))`
+const FsEnv = "$fs"
+
+// RunFS registers fsys under a temporary name and runs expr
+// against it. The name is supplied to the expression in the
+// FsEnv env var, and is unregistered when the test completes.
+// Any values in env are also supplied. fstest.MapFS is a
+// convenient way to build an in-memory fsys.
+func RunFS(t testing.TB, fsys fs.FS, expr string, env map[string]any) (*pipeline.RunOutput, error) {
+	t.Helper()
+	name := fmt.Sprintf("pipelinetest-%v", fsCounter.Add(1))
+	if err := pipeline.RegisterFs(name, fsys); err != nil {
+		return nil, err
+	}
+	t.Cleanup(func() {
+		pipeline.UnregisterFs(name)
+	})
+	runEnv := maps.Clone(env)
+	if runEnv == nil {
+		runEnv = make(map[string]any)
+	}
+	runEnv[FsEnv] = name
+	return pipeline.RunExpr(expr, nil, runEnv)
+}
+
+// MustRunFS is RunFS, failing the test on any error.
+func MustRunFS(t testing.TB, fsys fs.FS, expr string, env map[string]any) *pipeline.RunOutput {
+	t.Helper()
+	output, err := RunFS(t, fsys, expr, env)
+	if err != nil {
+		t.Fatalf("run %v err: %v", expr, err)
+	}
+	return output
+}
+
+// Content answers all ContentData in the pins.
+func Content(pins []pipeline.Pin) []*pipeline.ContentData {
+	var content []*pipeline.ContentData
+	for _, pin := range pins {
+		if cd, ok := pipeline.Unwrap(pin.Payload).(*pipeline.ContentData); ok {
+			content = append(content, cd)
+		}
+	}
+	return content
+}
+
+var fsCounter atomic.Int64
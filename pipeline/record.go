@@ -0,0 +1,228 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+
+	oferrors "github.com/hackborn/onefunc/errors"
+)
+
+// Record runs the pipeline, answering the output along with a
+// Recording of the run. The Recording contains everything needed
+// to run any single node again in isolation, see Replay().
+// Every payload that passes through the pipeline must have been
+// registered with RegisterPayload.
+func Record(p *Pipeline, input *RunInput, env map[string]any) (*RunOutput, *Recording, error) {
+	rec := newRecorder(input, env)
+	output, err := run(p, input, env, rec)
+	if err != nil {
+		return nil, nil, err
+	}
+	rec.eb.AddError(rec.r.Output.encode(output.Pins))
+	if rec.eb.Err != nil {
+		return nil, nil, fmt.Errorf("Pipeline: record err: %w", rec.eb.Err)
+	}
+	return output, rec.r, nil
+}
+
+// Replay runs the named node in isolation, using the input, vars
+// and env recorded in r. The result can be compared against the
+// recorded output of the node to find where a run diverged.
+func Replay(r *Recording, nodeName string) (*RunOutput, error) {
+	rn, ok := r.Node(nodeName)
+	if !ok {
+		return nil, fmt.Errorf("Pipeline: no recorded node named \"%v\"", nodeName)
+	}
+	cn, err := compileNode(rn.Name, rn.Vars, rn.EnvVars)
+	if err != nil {
+		return nil, err
+	}
+	build := newBuildRun([]*compiledNode{cn})
+	running := build.running[cn]
+	if err = build.buildNode(running, r.Env); err != nil {
+		return nil, fmt.Errorf("Pipeline: build node err %w", err)
+	}
+	pins, err := rn.Input.decode()
+	if err != nil {
+		return nil, err
+	}
+	state := &State{NodeData: running.nodeData}
+	output := &RunOutput{}
+	if err = cn.node.Run(state, NewRunInput(pins...), output); err != nil {
+		return nil, fmt.Errorf("Pipeline: %T run err: %w", cn.node, err)
+	}
+	if err = flush(state, cn.flusher, output); err != nil {
+		return nil, fmt.Errorf("Pipeline: %T flush err: %w", cn.node, err)
+	}
+	return output, nil
+}
+
+// Diverged replays every node in the recording and answers
+// the names of the nodes whose output no longer matches.
+func Diverged(r *Recording) ([]string, error) {
+	var names []string
+	for _, rn := range r.Nodes {
+		output, err := Replay(r, rn.Name)
+		if err != nil {
+			return nil, err
+		}
+		var have RecordedPins
+		if err = have.encode(output.Pins); err != nil {
+			return nil, err
+		}
+		if !have.Equal(rn.Output) {
+			names = append(names, rn.Name)
+		}
+	}
+	return names, nil
+}
+
+// Recording is a snapshot of a single pipeline run. It is
+// plain JSON, so it can be saved and loaded with the io package
+// (io.WriteJson() and io.ReadJson[pipeline.Recording]()).
+type Recording struct {
+	// The input supplied to the root nodes.
+	Input RecordedPins `json:"input,omitempty"`
+
+	// The env supplied to the run.
+	Env map[string]any `json:"env,omitempty"`
+
+	// Every node, in the order it was run.
+	Nodes []*RecordedNode `json:"nodes,omitempty"`
+
+	// The final output of the run.
+	Output RecordedPins `json:"output,omitempty"`
+}
+
+// Node answers the recorded node with the given name.
+func (r *Recording) Node(name string) (*RecordedNode, bool) {
+	for _, rn := range r.Nodes {
+		if rn.Name == name {
+			return rn, true
+		}
+	}
+	return nil, false
+}
+
+// RecordedNode is the data for a single node in a Recording.
+type RecordedNode struct {
+	// Name is the name of the node in the pipeline expression.
+	Name    string            `json:"name"`
+	Vars    map[string]any    `json:"vars,omitempty"`
+	EnvVars map[string]string `json:"envVars,omitempty"`
+
+	// Input is the complete input supplied to Run.
+	Input RecordedPins `json:"input,omitempty"`
+
+	// Output is the complete output after Run and Flush.
+	Output RecordedPins `json:"output,omitempty"`
+}
+
+// RecordedPins stores pins in serialized form.
+type RecordedPins []RecordedPin
+
+// Equal answers true if both lists have the same pins
+// with the same serialized payloads.
+func (p RecordedPins) Equal(b RecordedPins) bool {
+	if len(p) != len(b) {
+		return false
+	}
+	for i, pin := range p {
+		if pin.Name != b[i].Name || pin.Policy != b[i].Policy ||
+			pin.Type != b[i].Type || !bytes.Equal(pin.Payload, b[i].Payload) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *RecordedPins) encode(pins []Pin) error {
+	*p = nil
+	for _, pin := range pins {
+		rp := RecordedPin{Name: pin.Name, Policy: pin.Policy}
+		// Cow wrappers are an optimization, not data, so
+		// only the wrapped value is recorded.
+		if payload := Unwrap(pin.Payload); payload != nil {
+			name, err := regPayload.nameOf(payload)
+			if err != nil {
+				return err
+			}
+			dat, err := json.Marshal(payload)
+			if err != nil {
+				return err
+			}
+			rp.Type = name
+			rp.Payload = dat
+		}
+		*p = append(*p, rp)
+	}
+	return nil
+}
+
+func (p RecordedPins) decode() ([]Pin, error) {
+	pins := make([]Pin, 0, len(p))
+	for _, rp := range p {
+		pin := Pin{Name: rp.Name, Policy: rp.Policy}
+		if rp.Type != "" {
+			payload, err := regPayload.new(rp.Type)
+			if err != nil {
+				return nil, err
+			}
+			if err = json.Unmarshal(rp.Payload, payload); err != nil {
+				return nil, err
+			}
+			pin.Payload = payload
+		}
+		pins = append(pins, pin)
+	}
+	return pins, nil
+}
+
+// RecordedPin is a single serialized pin.
+type RecordedPin struct {
+	Name   string      `json:"name,omitempty"`
+	Policy ClonePolicy `json:"policy,omitempty"`
+	// Type is the name the payload was registered under.
+	Type    string          `json:"type,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// recorder captures node data while a pipeline runs.
+// All functions are safe to call on a nil recorder.
+type recorder struct {
+	r     *Recording
+	nodes map[*runningNode]*RecordedNode
+	eb    oferrors.FirstBlock
+}
+
+func newRecorder(input *RunInput, env map[string]any) *recorder {
+	rec := &recorder{r: &Recording{Env: maps.Clone(env)},
+		nodes: make(map[*runningNode]*RecordedNode)}
+	if input != nil {
+		rec.eb.AddError(rec.r.Input.encode(input.Pins))
+	}
+	return rec
+}
+
+// input records the node and its input. Payloads are
+// serialized immediately, because nodes are free to modify them.
+func (r *recorder) input(rn *runningNode) {
+	if r == nil {
+		return
+	}
+	node := &RecordedNode{Name: rn.cn.name, Vars: rn.cn.vars, EnvVars: rn.cn.envVars}
+	r.eb.AddError(node.Input.encode(rn.input.Pins))
+	r.nodes[rn] = node
+	r.r.Nodes = append(r.r.Nodes, node)
+}
+
+func (r *recorder) output(rn *runningNode, output *RunOutput) {
+	if r == nil {
+		return
+	}
+	if node, ok := r.nodes[rn]; ok {
+		r.eb.AddError(node.Output.encode(output.Pins))
+	}
+}
@@ -14,6 +14,11 @@ func RegisterFs(name string, fsys fs.FS) error {
 	return regFs.Register(name, fsys)
 }
 
+// UnregisterFs removes a named file system from the registry.
+func UnregisterFs(name string) {
+	regFs.Unregister(name)
+}
+
 func FindFs(name string) (fs.FS, bool) {
 	return regFs.Find(name)
 }
@@ -38,6 +43,11 @@ func (r *registryFs) Register(name string, fsys fs.FS) error {
 	return nil
 }
 
+func (r *registryFs) Unregister(name string) {
+	defer sync.Lock(&r.lock).Unlock()
+	delete(r.systems, name)
+}
+
 func (r *registryFs) Find(name string) (fs.FS, bool) {
 	defer sync.Lock(&r.lock).Unlock()
 	if f, ok := r.systems[name]; ok {
@@ -0,0 +1,66 @@
+package pipeline
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/hackborn/onefunc/sync"
+)
+
+// NewPayloadFunc answers a new, empty payload of a registered
+// type. Recordings decode saved payloads into its answer.
+type NewPayloadFunc func() Cloner
+
+// RegisterPayload adds a named payload type to the registry.
+// Payloads must be registered to be saved in a Recording, and
+// must survive a round trip through encoding/json. ContentData,
+// StructData and TreeData are registered by default.
+func RegisterPayload(name string, newfunc NewPayloadFunc) error {
+	return regPayload.register(name, newfunc)
+}
+
+// registryPayload stores a list of named payload types.
+type registryPayload struct {
+	lock  sync.Mutex
+	funcs map[string]NewPayloadFunc
+	names map[reflect.Type]string
+}
+
+func newRegistryPayload() *registryPayload {
+	r := &registryPayload{funcs: make(map[string]NewPayloadFunc),
+		names: make(map[reflect.Type]string)}
+	r.register("ContentData", func() Cloner { return &ContentData{} })
+	r.register("StructData", func() Cloner { return &StructData{} })
+	r.register("TreeData", func() Cloner { return &TreeData{} })
+	return r
+}
+
+func (r *registryPayload) register(name string, newfunc NewPayloadFunc) error {
+	defer sync.Lock(&r.lock).Unlock()
+	if _, ok := r.funcs[name]; ok {
+		return fmt.Errorf("Payload \"%v\" already registered", name)
+	}
+	r.funcs[name] = newfunc
+	r.names[reflect.TypeOf(newfunc())] = name
+	return nil
+}
+
+func (r *registryPayload) new(name string) (Cloner, error) {
+	defer sync.Lock(&r.lock).Unlock()
+	if fn, ok := r.funcs[name]; ok {
+		return fn(), nil
+	}
+	return nil, fmt.Errorf("Payload \"%v\" is not registered", name)
+}
+
+func (r *registryPayload) nameOf(payload Cloner) (string, error) {
+	defer sync.Lock(&r.lock).Unlock()
+	if name, ok := r.names[reflect.TypeOf(payload)]; ok {
+		return name, nil
+	}
+	return "", fmt.Errorf("Payload type %T is not registered", payload)
+}
+
+var (
+	regPayload *registryPayload = newRegistryPayload()
+)
@@ -15,6 +15,12 @@ func RunExpr(expr string, input *RunInput, env map[string]any) (*RunOutput, erro
 }
 
 func Run(p *Pipeline, input *RunInput, env map[string]any) (*RunOutput, error) {
+	return run(p, input, env, nil)
+}
+
+// run executes the pipeline, optionally reporting each
+// node's input and output to a recorder.
+func run(p *Pipeline, input *RunInput, env map[string]any, rec *recorder) (*RunOutput, error) {
 	build := newBuildRun(p.nodes)
 	running, err := build.buildPipeline(p, input, env)
 	if err != nil {
@@ -30,6 +36,7 @@ func Run(p *Pipeline, input *RunInput, env map[string]any) (*RunOutput, error) {
 		for _, rn := range running {
 			state.NodeData = rn.nodeData
 			runOutput.Pins = outputPins[:0]
+			rec.input(rn)
 			err := rn.cn.node.Run(state, rn.input, runOutput)
 			if err != nil {
 				return nil, fmt.Errorf("Pipeline: %T run err: %w", rn.cn.node, err)
@@ -39,12 +46,13 @@ func Run(p *Pipeline, input *RunInput, env map[string]any) (*RunOutput, error) {
 			if err != nil {
 				return nil, fmt.Errorf("Pipeline: %T flush err: %w", rn.cn.node, err)
 			}
+			rec.output(rn, runOutput)
 
 			if len(rn.output) > 0 {
 				fanout := runFanOut{}
-				for _, topin := range rn.output {
+				for i, topin := range rn.output {
 					topin.toNode.inputCount++
-					fanout.on(topin, runOutput)
+					fanout.on(i, topin, runOutput)
 					if topin.toNode.ready() {
 						topin.toNode.isReady = true
 					}
@@ -72,18 +80,19 @@ func flush(state *State, flusher Flusher, output *RunOutput) error {
 }
 
 // runFanOut is responsible for adding runOutput to the input of
-// destination nodes, cloning according to policy.
+// destination nodes, cloning according to policy. The index is
+// the destination's position in the fan out; under SmartClone
+// the first destination receives the original payloads and
+// every other destination receives a clone.
 type runFanOut struct {
 }
 
-func (f *runFanOut) on(topin *runningPin, runOutput *RunOutput) {
+func (f *runFanOut) on(index int, topin *runningPin, runOutput *RunOutput) {
 	if len(runOutput.Pins) < 1 {
 		return
 	}
 	tonode := topin.toNode
-	first := -1
 	for _, pin := range runOutput.Pins {
-		first++
 		if pin.Payload == nil {
 			tonode.input.Pins = append(tonode.input.Pins, pin)
 			continue
@@ -94,7 +103,7 @@ func (f *runFanOut) on(topin *runningPin, runOutput *RunOutput) {
 			newpin.Payload = pin.Payload.Clone()
 		case NeverClone:
 		default:
-			if first > 0 {
+			if index > 0 {
 				newpin.Payload = pin.Payload.Clone()
 			}
 		}
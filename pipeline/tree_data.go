@@ -0,0 +1,159 @@
+package pipeline
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TreeData provides a generic tree of structured data, such
+// as a decoded JSON, CSV or XML file. The tree is made of the
+// same types encoding/json produces when unmarshalling into an
+// any: map[string]any, []any and scalar values.
+//
+// Values are addressed with the same path syntax as cfg.Settings
+// ("path/to/value"), where slice elements are addressed by index
+// ("rows/0/name"). An empty path addresses the root.
+type TreeData struct {
+	// The name of the source data.
+	Name string
+
+	// The root of the tree, typically a map[string]any.
+	Value any
+
+	// The format the tree was decoded from ("json", "csv", "xml").
+	Format string
+}
+
+func (d *TreeData) Clone() Cloner {
+	dst := *d
+	dst.Value = cloneTree(d.Value)
+	return &dst
+}
+
+// Get answers the value at path.
+func (d *TreeData) Get(path string) (any, bool) {
+	v := d.Value
+	for _, key := range splitTreePath(path) {
+		switch t := v.(type) {
+		case map[string]any:
+			var ok bool
+			if v, ok = t[key]; !ok {
+				return nil, false
+			}
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil, false
+			}
+			v = t[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// Set assigns value to path, creating any missing maps along
+// the way. A slice index can address an existing element, or one
+// past the end to append.
+func (d *TreeData) Set(path string, value any) error {
+	keys := splitTreePath(path)
+	if len(keys) < 1 {
+		d.Value = value
+		return nil
+	}
+	var err error
+	d.Value, err = setTree(d.Value, keys, value)
+	if err != nil {
+		return fmt.Errorf("TreeData set \"%v\": %w", path, err)
+	}
+	return nil
+}
+
+// Delete removes the value at path, answering false if
+// there was no value.
+func (d *TreeData) Delete(path string) bool {
+	keys := splitTreePath(path)
+	if len(keys) < 1 {
+		return false
+	}
+	last := len(keys) - 1
+	parent, ok := d.Get(strings.Join(keys[:last], "/"))
+	if !ok {
+		return false
+	}
+	switch t := parent.(type) {
+	case map[string]any:
+		if _, ok := t[keys[last]]; ok {
+			delete(t, keys[last])
+			return true
+		}
+	case []any:
+		i, err := strconv.Atoi(keys[last])
+		if err != nil || i < 0 || i >= len(t) {
+			return false
+		}
+		// The parent slice shrinks, so it must be reassigned.
+		return d.Set(strings.Join(keys[:last], "/"), append(t[:i], t[i+1:]...)) == nil
+	}
+	return false
+}
+
+func setTree(v any, keys []string, value any) (any, error) {
+	if len(keys) < 1 {
+		return value, nil
+	}
+	key := keys[0]
+	switch t := v.(type) {
+	case nil:
+		m := make(map[string]any)
+		child, err := setTree(nil, keys[1:], value)
+		m[key] = child
+		return m, err
+	case map[string]any:
+		child, err := setTree(t[key], keys[1:], value)
+		t[key] = child
+		return t, err
+	case []any:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i > len(t) {
+			return t, fmt.Errorf("bad index \"%v\"", key)
+		}
+		if i == len(t) {
+			t = append(t, nil)
+		}
+		t[i], err = setTree(t[i], keys[1:], value)
+		return t, err
+	default:
+		return v, fmt.Errorf("can't set \"%v\" on %T", key, v)
+	}
+}
+
+func splitTreePath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// cloneTree makes a deep copy of the maps and slices in v.
+func cloneTree(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(t))
+		for k, tv := range t {
+			m[k] = cloneTree(tv)
+		}
+		return m
+	case []any:
+		s := make([]any, len(t))
+		for i, tv := range t {
+			s[i] = cloneTree(tv)
+		}
+		return s
+	default:
+		return v
+	}
+}
@@ -13,3 +13,14 @@ type _startInput struct {
 func (s *_startInput) SetNodeData(nd any) {
 	s.nodeData = nd
 }
+
+// Mutable answers a payload the node is free to modify and
+// replaces the pin's payload with it. A payload wrapped in a
+// Cow is unwrapped, and only copied if it is still shared
+// with other pins. Any other payload is answered as-is.
+func (s *State) Mutable(pin *Pin) Cloner {
+	if c, ok := pin.Payload.(*Cow); ok {
+		pin.Payload = c.take()
+	}
+	return pin.Payload
+}